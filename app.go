@@ -1,33 +1,170 @@
 package goweb
 
 import (
-	"fmt"
+	stdctx "context"
+	"github.com/liuyongshuai/thingo/context"
 	"github.com/liuyongshuai/thingo/controller"
 	"github.com/liuyongshuai/thingo/router"
+	"github.com/liuyongshuai/thingo/websocket"
 	"html/template"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 )
 
+//生命周期回调函数
+type LifecycleFunc func(app *ThingoApp)
+
 //新建一个APP
 func NewThingoApp() *ThingoApp {
 	app := &ThingoApp{
 		Handlers: NewThingoHandler(),
 	}
+	//url_for按本APP的路由生成URL
+	app.Handlers.Tpl.AddTplFunc("url_for", app.URLFor)
 	return app
 }
 
 //APP结构体
 type ThingoApp struct {
-	Handlers *ThingoHandler //处理句柄
+	Handlers     *ThingoHandler  //处理句柄
+	Server       *http.Server    //底层的http服务，Run时创建
+	startFuncs   []LifecycleFunc //启动时的回调
+	stopFuncs    []LifecycleFunc //关闭时的回调
+	shutdownOnce sync.Once       //保证关闭流程只执行一次
+	shutdownErr  error           //关闭流程的结果
+	serverLock   sync.Mutex      //保护Server及closed
+	closed       bool            //是否已经开始关闭，之后不再启动服务
+}
+
+//开始运行，直到服务关闭才返回，正常关闭时返回nil
+func (app *ThingoApp) Run() error {
+	ln, err := app.listen()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return app.serve(ln)
 }
 
-//开始运行
-func (app *ThingoApp) Run() {
+//创建底层的http服务并开始监听，已经开始关闭时返回http.ErrServerClosed
+func (app *ThingoApp) listen() (net.Listener, error) {
+	app.serverLock.Lock()
+	defer app.serverLock.Unlock()
+	if app.closed {
+		return nil, http.ErrServerClosed
+	}
 	app.Handlers.Tpl.SetRootPathDir(app.Handlers.TplDir).SetTplExt(app.Handlers.TplExt)
-	err := http.ListenAndServe(":"+app.Handlers.Port, app.Handlers)
+	srv := &http.Server{
+		Addr:    ":" + app.Handlers.Port,
+		Handler: app.Handlers,
+	}
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return nil, err
+	}
+	app.Server = srv
+	return ln, nil
+}
+
+//在ln上提供服务，直到服务关闭，正常关闭时返回nil
+func (app *ThingoApp) serve(ln net.Listener) error {
+	for _, fn := range app.startFuncs {
+		fn(app)
+	}
+	app.serverLock.Lock()
+	srv := app.Server
+	app.serverLock.Unlock()
+	//Shutdown之后再调用Serve会直接返回http.ErrServerClosed
+	err := srv.Serve(ln)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+/**
+开始运行，并在收到指定的信号后平滑关闭，默认监听SIGINT、SIGTERM
+	timeout：关闭时等待正在处理的请求结束的最长时间，小于等于0表示一直等待
+*/
+func (app *ThingoApp) RunGraceful(timeout time.Duration, sigs ...os.Signal) error {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, sigs...)
+	defer signal.Stop(sigChan)
+
+	//先同步创建好服务并监听，保证收到信号时Shutdown能关闭它
+	ln, err := app.listen()
+	if err == http.ErrServerClosed {
+		return nil
+	}
 	if err != nil {
-		fmt.Println(err)
+		return err
+	}
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- app.serve(ln)
+	}()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-sigChan:
+	}
+
+	ctx := stdctx.Background()
+	if timeout > 0 {
+		var cancel stdctx.CancelFunc
+		ctx, cancel = stdctx.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if err := app.Shutdown(ctx); err != nil {
+		return err
 	}
+	return <-errChan
+}
+
+/**
+平滑关闭服务：先停止接收新连接，再等待正在执行的控制层及After插件结束，
+最后执行OnShutdown注册的回调。ctx到期时返回ctx.Err()，但回调仍会执行
+*/
+func (app *ThingoApp) Shutdown(ctx stdctx.Context) error {
+	app.shutdownOnce.Do(func() {
+		app.serverLock.Lock()
+		app.closed = true
+		srv := app.Server
+		app.serverLock.Unlock()
+		if srv != nil {
+			app.shutdownErr = srv.Shutdown(ctx)
+		}
+		if err := app.Handlers.Wait(ctx); err != nil && app.shutdownErr == nil {
+			app.shutdownErr = err
+		}
+		for _, fn := range app.stopFuncs {
+			fn(app)
+		}
+	})
+	return app.shutdownErr
+}
+
+//注册服务开始监听后的回调
+func (app *ThingoApp) OnStart(fn LifecycleFunc) *ThingoApp {
+	app.startFuncs = append(app.startFuncs, fn)
+	return app
+}
+
+//注册服务关闭后的回调，一般用来释放数据库连接等资源
+func (app *ThingoApp) OnShutdown(fn LifecycleFunc) *ThingoApp {
+	app.stopFuncs = append(app.stopFuncs, fn)
+	return app
 }
 
 //设置监听端口
@@ -49,6 +186,50 @@ func (app *ThingoApp) SetMaxMemory(n int64) *ThingoApp {
 	return app
 }
 
+//设置信任的代理，如"10.0.0.0/8"、"127.0.0.1"，格式有误时直接panic
+func (app *ThingoApp) SetTrustedProxies(proxies ...string) *ThingoApp {
+	if err := app.Handlers.SetTrustedProxies(proxies...); err != nil {
+		panic(err)
+	}
+	return app
+}
+
+//设置解析请求body时是否不允许有未知的字段
+func (app *ThingoApp) SetStrictBody(strict bool) *ThingoApp {
+	app.Handlers.SetStrictBody(strict)
+	return app
+}
+
+//设置自动生成ETag的方式，如context.ETagStrong，context.ETagNone为不生成
+func (app *ThingoApp) SetETag(mode int) *ThingoApp {
+	app.Handlers.SetETag(mode)
+	return app
+}
+
+//设置默认的缓存策略，为nil时不输出Cache-Control
+func (app *ThingoApp) SetCachePolicy(p *context.CachePolicy) *ThingoApp {
+	app.Handlers.SetCachePolicy(p)
+	return app
+}
+
+//设置每个响应默认带的头信息，已有时覆盖
+func (app *ThingoApp) SetDefaultHeader(key, val string) *ThingoApp {
+	app.Handlers.SetDefaultHeader(key, val)
+	return app
+}
+
+//给每个响应默认带的头信息再添加一个值
+func (app *ThingoApp) AddDefaultHeader(key, val string) *ThingoApp {
+	app.Handlers.AddDefaultHeader(key, val)
+	return app
+}
+
+//去掉默认带的头信息，如去掉品牌相关的：RemoveDefaultHeader("X-Powered-By", "Server", "Github")
+func (app *ThingoApp) RemoveDefaultHeader(keys ...string) *ThingoApp {
+	app.Handlers.RemoveDefaultHeader(keys...)
+	return app
+}
+
 //设置模板路径
 func (app *ThingoApp) SetTplDir(dir string) *ThingoApp {
 	app.Handlers.SetTplDir(dir)
@@ -73,9 +254,25 @@ func (app *ThingoApp) AddTplCommonData(k interface{}, v interface{}) *ThingoApp
 	return app
 }
 
-//添加一个插件
+//添加一个插件，when只能是HooksBeforeRun、HooksBeforeSend等已知的类型，否则直接panic
 func (app *ThingoApp) AddHooks(when int, hk HooksFunc) *ThingoApp {
-	app.Handlers.AddHooks(when, hk)
+	if err := app.Handlers.AddHooks(when, hk); err != nil {
+		panic(err)
+	}
+	return app
+}
+
+//添加一个带优先级的插件，priority越小越先执行，相同时按添加的顺序，when不是已知的类型时直接panic
+func (app *ThingoApp) AddHooksWithPriority(when int, priority int, hk HooksFunc) *ThingoApp {
+	if err := app.Handlers.AddHooksWithPriority(when, priority, hk); err != nil {
+		panic(err)
+	}
+	return app
+}
+
+//添加全局的中间件，按添加的顺序由外到内执行
+func (app *ThingoApp) Use(mws ...MiddlewareFunc) *ThingoApp {
+	app.Handlers.Use(mws...)
 	return app
 }
 
@@ -103,6 +300,91 @@ func (app *ThingoApp) AddRouters(rs ...*router.ThingoRouterItem) *ThingoApp {
 	return app
 }
 
+//添加一个只响应指定请求方法的全路径路由
+func (app *ThingoApp) AddMethodRouter(methods []string, config string, c controller.ThingoControllerInterface) *ThingoApp {
+	app.Handlers.AddRouter(&router.ThingoRouterItem{
+		Type:       router.RouterTypePathInfo,
+		Config:     config,
+		Controller: c,
+		Methods:    methods,
+	})
+	return app
+}
+
+//添加一个由函数处理的全路径路由，methods为空时不限制请求方法
+func (app *ThingoApp) HandleFunc(config string, fn func(ctx *context.ThingoContext), methods ...string) *ThingoApp {
+	app.Handlers.AddRouter(&router.ThingoRouterItem{
+		Type:    router.RouterTypePathInfo,
+		Config:  config,
+		Handler: fn,
+		Methods: methods,
+	})
+	return app
+}
+
+//添加一个由标准库http.Handler处理的全路径路由，methods为空时不限制请求方法
+func (app *ThingoApp) Handle(config string, h http.Handler, methods ...string) *ThingoApp {
+	app.Handlers.AddRouter(&router.ThingoRouterItem{
+		Type:    router.RouterTypePathInfo,
+		Config:  config,
+		Handler: h,
+		Methods: methods,
+	})
+	return app
+}
+
+//添加WebSocket路由，只允许GET请求，fn返回后连接自动关闭
+func (app *ThingoApp) WebSocket(config string, fn websocket.HandlerFunc, opts ...*websocket.Options) *ThingoApp {
+	return app.HandleFunc(config, websocket.Handler(fn, opts...), http.MethodGet)
+}
+
+//添加GET路由，同时会响应HEAD请求
+func (app *ThingoApp) Get(config string, c controller.ThingoControllerInterface) *ThingoApp {
+	return app.AddMethodRouter([]string{http.MethodGet}, config, c)
+}
+
+//添加POST路由
+func (app *ThingoApp) Post(config string, c controller.ThingoControllerInterface) *ThingoApp {
+	return app.AddMethodRouter([]string{http.MethodPost}, config, c)
+}
+
+//添加PUT路由
+func (app *ThingoApp) Put(config string, c controller.ThingoControllerInterface) *ThingoApp {
+	return app.AddMethodRouter([]string{http.MethodPut}, config, c)
+}
+
+//添加PATCH路由
+func (app *ThingoApp) Patch(config string, c controller.ThingoControllerInterface) *ThingoApp {
+	return app.AddMethodRouter([]string{http.MethodPatch}, config, c)
+}
+
+//添加DELETE路由
+func (app *ThingoApp) Delete(config string, c controller.ThingoControllerInterface) *ThingoApp {
+	return app.AddMethodRouter([]string{http.MethodDelete}, config, c)
+}
+
+//新建一个路由分组，组内的路由共用路径前缀、域名限制及前后插件
+func (app *ThingoApp) Group(prefix string) *router.ThingoRouterGroup {
+	return app.Handlers.Group(prefix)
+}
+
+//按路由名称反向生成URL，params为依次排列的参数名及参数值，多余的参数拼成query string，模板里的url_for也用它
+func (app *ThingoApp) URLFor(name string, params ...interface{}) (string, error) {
+	return app.Handlers.Router.URLFor(name, params...)
+}
+
+//设置默认域名，请求的域名匹配不上任何路由时按它来匹配
+func (app *ThingoApp) SetDefaultHost(host string) *ThingoApp {
+	app.Handlers.SetDefaultHost(host)
+	return app
+}
+
+//设置路由缓存，size小于等于0表示不缓存，staticOnly表示只缓存不带参数的路由
+func (app *ThingoApp) SetRouterCache(size int, staticOnly bool) *ThingoApp {
+	app.Handlers.SetRouterCache(size, staticOnly)
+	return app
+}
+
 //设置发生错误时的处理函数
 func (app *ThingoApp) SetRecoverFunc(fn RecoverFunc) *ThingoApp {
 	app.Handlers.SetRecoverFunc(fn)
@@ -0,0 +1,132 @@
+package goweb
+
+import (
+	"bytes"
+	stdctx "context"
+	"github.com/liuyongshuai/thingo/context"
+	"github.com/liuyongshuai/thingo/router"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+//服务刚启动就收到信号，RunGraceful也要能正常返回
+func TestRunGracefulEarlySignal(t *testing.T) {
+	//先自己接收SIGUSR1，避免信号在RunGraceful注册之前到达时进程被终止
+	own := make(chan os.Signal, 16)
+	signal.Notify(own, syscall.SIGUSR1)
+	defer signal.Stop(own)
+
+	app := NewThingoApp().SetPort("0")
+	stopped := false
+	app.OnShutdown(func(*ThingoApp) { stopped = true })
+
+	done := make(chan error, 1)
+	go func() {
+		done <- app.RunGraceful(time.Second, syscall.SIGUSR1)
+	}()
+	deadline := time.After(5 * time.Second)
+	for {
+		syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("RunGraceful returned %v", err)
+			}
+			if !stopped {
+				t.Error("OnShutdown callback not called")
+			}
+			return
+		case <-deadline:
+			t.Fatal("RunGraceful did not return after signal")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+//已经关闭后再调用Run直接返回，不再启动服务
+func TestShutdownBeforeRun(t *testing.T) {
+	app := NewThingoApp().SetPort("0")
+	if err := app.Shutdown(stdctx.Background()); err != nil {
+		t.Fatalf("Shutdown returned %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- app.Run()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run kept serving after Shutdown")
+	}
+}
+
+//Run和Shutdown在不同的goroutine里调用
+func TestShutdownWhileRunning(t *testing.T) {
+	app := NewThingoApp().SetPort("0")
+	started := make(chan struct{})
+	app.OnStart(func(*ThingoApp) { close(started) })
+	done := make(chan error, 1)
+	go func() {
+		done <- app.Run()
+	}()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not start")
+	}
+	if err := app.Shutdown(stdctx.Background()); err != nil {
+		t.Fatalf("Shutdown returned %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after Shutdown")
+	}
+}
+
+//每个APP的模板里url_for都按自己的路由生成URL
+func TestURLForPerApp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thingo-tpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tpl := `{{url_for "item" "id" 7 "from" "home"}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "link.tpl"), []byte(tpl), 0644); err != nil {
+		t.Fatal(err)
+	}
+	//两个APP都建好后再输出，确保没有共用的全局状态
+	fn := func(*context.ThingoContext) {}
+	apps := map[string]*ThingoApp{}
+	for _, prefix := range []string{"/a", "/b"} {
+		app := NewThingoApp()
+		app.AddRouter(&router.ThingoRouterItem{
+			Type:    router.RouterTypePathInfo,
+			Config:  prefix + "/item/:id<int>",
+			Name:    "item",
+			Handler: fn,
+		})
+		app.Handlers.Tpl.SetRootPathDir(dir)
+		apps[prefix] = app
+	}
+	for prefix, app := range apps {
+		var buf bytes.Buffer
+		if err := app.Handlers.Tpl.ExecuteTpl(&buf, "link.tpl", nil); err != nil {
+			t.Fatalf("%s: ExecuteTpl: %v", prefix, err)
+		}
+		if want := prefix + "/item/7?from=home"; buf.String() != want {
+			t.Errorf("%s: url_for = %q, want %q", prefix, buf.String(), want)
+		}
+	}
+}
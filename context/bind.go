@@ -0,0 +1,476 @@
+package context
+
+import (
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+按struct tag把请求参数填充到结构体里，并执行校验，支持的tag：
+	param:"id"			路由里的参数
+	query:"page"		URL里的参数，切片类型时取所有的值
+	form:"name"			POST表单里的参数，含multipart，类型为*multipart.FileHeader时为上传的文件
+	header:"X-Token"	头信息
+	cookie:"sid"		cookie
+	default:"10"		以上都没有值时的默认值
+	time_format:"2006-01-02"	time.Time类型的格式，默认RFC3339
+	validate:"required,min=1,max=100"	校验规则，见validateField
+
+如：
+
+	type ListReq struct {
+		Id    int64    `param:"id" validate:"required,min=1"`
+		Page  int      `query:"page" default:"1" validate:"min=1,max=100"`
+		Tags  []string `query:"tag"`
+		Token string   `header:"X-Token" validate:"omitempty,len=32"`
+	}
+
+嵌套的结构体、结构体指针会继续往下绑定，指针为nil时只有请求里有值才会新建
+
+类型转换失败或校验不通过时，返回的错误为BindErrors，包含所有字段的错误
+*/
+func (input *ThingoInput) Bind(dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("bind: dst must be a non-nil pointer to struct, got %T", dst)
+	}
+	var errs BindErrors
+	input.bindStruct(rv.Elem(), &errs)
+	validateStruct(rv.Elem(), &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+//对结构体执行validate标签里的校验规则，不从请求里取值
+func Validate(v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("validate: %T is not a struct", v)
+	}
+	var errs BindErrors
+	validateStruct(rv, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+//单个字段的错误
+type FieldError struct {
+	Field string //结构体里的字段名
+	Rule  string //没通过的规则，类型转换失败时为“type”
+	Value string //原始的值
+	Msg   string //错误描述
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Msg
+}
+
+//绑定、校验时的所有字段错误
+type BindErrors []*FieldError
+
+func (es BindErrors) Error() string {
+	msgs := make([]string, 0, len(es))
+	for _, e := range es {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+//对应的HTTP状态码
+func (es BindErrors) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+//按字段名提取错误，没有时返回nil
+func (es BindErrors) Field(name string) *FieldError {
+	for _, e := range es {
+		if e.Field == name {
+			return e
+		}
+	}
+	return nil
+}
+
+//绑定时依次查找的tag
+var bindSources = []string{"param", "query", "form", "header", "cookie"}
+
+var (
+	fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType = reflect.TypeOf([]*multipart.FileHeader(nil))
+	timeType        = reflect.TypeOf(time.Time{})
+	durationType    = reflect.TypeOf(time.Duration(0))
+)
+
+//按tag填充结构体的各个字段，返回是否有字段从请求里取到了值
+func (input *ThingoInput) bindStruct(rv reflect.Value, errs *BindErrors) bool {
+	rt := rv.Type()
+	bound := false
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		fv := rv.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		vals, found := input.bindValues(sf)
+		//没有任何来源的tag时，嵌套的结构体继续往下找
+		if !found && sf.Tag.Get("default") == "" {
+			if input.bindNested(fv, errs) {
+				bound = true
+			}
+			continue
+		}
+		if fv.Type() == fileHeaderType || fv.Type() == fileHeadersType {
+			if input.bindFiles(sf, fv) {
+				bound = true
+			}
+			continue
+		}
+		if len(vals) > 0 {
+			bound = true
+		} else {
+			if def, ok := sf.Tag.Lookup("default"); ok {
+				vals = []string{def}
+			} else {
+				continue
+			}
+		}
+		if err := setFieldValues(fv, vals, sf.Tag.Get("time_format")); err != nil {
+			*errs = append(*errs, &FieldError{Field: sf.Name, Rule: "type", Value: strings.Join(vals, ","), Msg: err.Error()})
+		}
+	}
+	return bound
+}
+
+//绑定嵌套的结构体或结构体指针，指针为nil时先绑定到新的结构体上，有值时才赋给字段
+func (input *ThingoInput) bindNested(fv reflect.Value, errs *BindErrors) bool {
+	switch {
+	case fv.Kind() == reflect.Struct && fv.Type() != timeType:
+		return input.bindStruct(fv, errs)
+	case fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct && fv.Type().Elem() != timeType:
+		if !fv.IsNil() {
+			return input.bindStruct(fv.Elem(), errs)
+		}
+		ptr := reflect.New(fv.Type().Elem())
+		if !input.bindStruct(ptr.Elem(), errs) {
+			return false
+		}
+		fv.Set(ptr)
+		return true
+	}
+	return false
+}
+
+//按tag提取字段对应的原始值，found表示字段上是否有来源的tag
+func (input *ThingoInput) bindValues(sf reflect.StructField) (vals []string, found bool) {
+	req := input.Context.Request
+	for _, src := range bindSources {
+		key, ok := sf.Tag.Lookup(src)
+		if !ok || key == "-" {
+			continue
+		}
+		found = true
+		switch src {
+		case "param":
+			if v, ok := input.Args[key]; ok {
+				vals = []string{v}
+			}
+		case "query":
+			vals = input.QueryArray(key)
+		case "form":
+			vals = input.FormArray(key)
+		case "header":
+			vals = req.Header[http.CanonicalHeaderKey(key)]
+		case "cookie":
+			if ck, err := req.Cookie(key); err == nil {
+				vals = []string{ck.Value}
+			}
+		}
+		if len(vals) > 0 {
+			return vals, true
+		}
+	}
+	return nil, found
+}
+
+//填充上传的文件，返回是否有文件
+func (input *ThingoInput) bindFiles(sf reflect.StructField, fv reflect.Value) bool {
+	req := input.Context.Request
+	key := sf.Tag.Get("form")
+	if key == "" || req.MultipartForm == nil {
+		return false
+	}
+	files := req.MultipartForm.File[key]
+	if len(files) == 0 {
+		return false
+	}
+	if fv.Type() == fileHeaderType {
+		fv.Set(reflect.ValueOf(files[0]))
+	} else {
+		fv.Set(reflect.ValueOf(files))
+	}
+	return true
+}
+
+//把字符串转换后赋给字段，切片类型会用上所有的值
+func setFieldValues(fv reflect.Value, vals []string, timeFormat string) error {
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+		for i, v := range vals {
+			if err := setFieldValue(slice.Index(i), v, timeFormat); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+	return setFieldValue(fv, vals[0], timeFormat)
+}
+
+//把字符串转换后赋给单个值
+func setFieldValue(fv reflect.Value, val string, timeFormat string) error {
+	if fv.Kind() == reflect.Ptr {
+		ptr := reflect.New(fv.Type().Elem())
+		if err := setFieldValue(ptr.Elem(), val, timeFormat); err != nil {
+			return err
+		}
+		fv.Set(ptr)
+		return nil
+	}
+	switch fv.Type() {
+	case timeType:
+		if timeFormat == "" {
+			timeFormat = time.RFC3339
+		}
+		t, err := time.ParseInLocation(timeFormat, val, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid time %q, layout %q", val, timeFormat)
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", val)
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("invalid bool %q", val)
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(val, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", val)
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(val, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", val)
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(val, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", val)
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		fv.SetBytes([]byte(val))
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
+
+//校验结构体的所有字段，嵌套的结构体也会校验
+func validateStruct(rv reflect.Value, errs *BindErrors) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		fv := rv.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		//类型转换已经失败的字段不再校验
+		if errs.Field(sf.Name) != nil {
+			continue
+		}
+		if rules := sf.Tag.Get("validate"); rules != "" && rules != "-" {
+			if fe := validateField(sf.Name, fv, rules); fe != nil {
+				*errs = append(*errs, fe)
+				continue
+			}
+		}
+		inner := reflect.Indirect(fv)
+		if inner.Kind() == reflect.Struct && inner.Type() != timeType {
+			validateStruct(inner, errs)
+		}
+	}
+}
+
+//编译过的正则规则
+var validateRegexps sync.Map
+
+//邮箱地址的格式
+var emailRegexp = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+/**
+按规则校验单个字段，规则之间用“,”隔开，regex规则要放在最后：
+	required	不能为零值
+	omitempty	为零值时不再校验后面的规则
+	min=n、max=n	数字比较大小，字符串、切片比较长度
+	len=n		字符串、切片的长度
+	oneof=a b c	只能是其中的一个值
+	email		邮箱地址
+	regex=^[a-z]+$	匹配正则
+
+除了omitempty，零值也要通过所有的规则，如 ?size=0 不能通过 min=1
+指针为nil时表示没有传值，只校验required，不为nil时即使指向零值也算有值
+*/
+func validateField(name string, fv reflect.Value, rules string) *FieldError {
+	var list []string
+	for rules != "" {
+		if strings.HasPrefix(rules, "regex=") {
+			list = append(list, rules)
+			break
+		}
+		pos := strings.Index(rules, ",")
+		if pos < 0 {
+			list = append(list, rules)
+			break
+		}
+		list = append(list, rules[:pos])
+		rules = rules[pos+1:]
+	}
+
+	v := reflect.Indirect(fv)
+	isNil := !v.IsValid()
+	isZero := isNil || v.IsZero()
+	//指针不为nil就是传了值，即使指向的是零值
+	if fv.Kind() == reflect.Ptr {
+		isZero = isNil
+	}
+	str := ""
+	if v.IsValid() {
+		str = fmt.Sprint(v.Interface())
+	}
+	for _, rule := range list {
+		rule = strings.TrimSpace(rule)
+		arg := ""
+		if pos := strings.Index(rule, "="); pos >= 0 {
+			rule, arg = rule[:pos], rule[pos+1:]
+		}
+		if rule == "required" {
+			if isZero {
+				return &FieldError{Field: name, Rule: rule, Msg: "is required"}
+			}
+			continue
+		}
+		if rule == "omitempty" {
+			if isZero {
+				return nil
+			}
+			continue
+		}
+		if isNil {
+			continue
+		}
+		if msg := checkRule(v, str, rule, arg); msg != "" {
+			return &FieldError{Field: name, Rule: rule, Value: str, Msg: msg}
+		}
+	}
+	return nil
+}
+
+//检查单条规则，通过时返回空字符串
+func checkRule(v reflect.Value, str, rule, arg string) string {
+	switch rule {
+	case "min", "max", "len":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return fmt.Sprintf("invalid rule %s=%s", rule, arg)
+		}
+		num, isLen := ruleNumber(v)
+		switch {
+		case rule == "len" && num != limit:
+			return fmt.Sprintf("length must be %s", arg)
+		case rule == "min" && num < limit && isLen:
+			return fmt.Sprintf("length must be at least %s", arg)
+		case rule == "min" && num < limit:
+			return fmt.Sprintf("must be at least %s", arg)
+		case rule == "max" && num > limit && isLen:
+			return fmt.Sprintf("length must be at most %s", arg)
+		case rule == "max" && num > limit:
+			return fmt.Sprintf("must be at most %s", arg)
+		}
+	case "oneof":
+		for _, o := range strings.Fields(arg) {
+			if o == str {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of [%s]", arg)
+	case "email":
+		if !emailRegexp.MatchString(str) {
+			return "must be a valid email address"
+		}
+		if _, err := mail.ParseAddress(str); err != nil {
+			return "must be a valid email address"
+		}
+	case "regex":
+		var reg *regexp.Regexp
+		if r, ok := validateRegexps.Load(arg); ok {
+			reg = r.(*regexp.Regexp)
+		} else {
+			r, err := regexp.Compile(arg)
+			if err != nil {
+				return fmt.Sprintf("invalid rule regex=%s", arg)
+			}
+			validateRegexps.Store(arg, r)
+			reg = r
+		}
+		if !reg.MatchString(str) {
+			return fmt.Sprintf("must match %s", arg)
+		}
+	default:
+		return fmt.Sprintf("unknown rule %s", rule)
+	}
+	return ""
+}
+
+//规则比较用的数值，字符串、切片、map用长度
+func ruleNumber(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(len([]rune(v.String()))), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false
+	}
+	return 0, false
+}
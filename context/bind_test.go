@@ -0,0 +1,326 @@
+package context
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+//新建一个POST表单请求的上下文
+func newFormContext(target, body string) *ThingoContext {
+	req := httptest.NewRequest("POST", target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ctx, _ := newTestContext(req)
+	ctx.Input.ParseFormOrMulitForm(defaultMaxMemory)
+	return ctx
+}
+
+func TestBindSources(t *testing.T) {
+	type req struct {
+		Id     int64  `param:"id"`
+		Page   int    `query:"page"`
+		Name   string `form:"name"`
+		Token  string `header:"X-Token"`
+		Sid    string `cookie:"sid"`
+		Size   int    `query:"size" default:"20"`
+		Lang   string `query:"lang" form:"lang" default:"en"`
+		Skip   string `query:"-"`
+		Ignore string
+	}
+	ctx := newFormContext("/x?page=3&lang=zh&Skip=1&Ignore=1", "name=bob&lang=fr")
+	ctx.Input.SetParam("id", "42")
+	ctx.Request.Header.Set("X-Token", "abc")
+	ctx.Request.AddCookie(&http.Cookie{Name: "sid", Value: "s1"})
+
+	var got req
+	if err := ctx.Input.Bind(&got); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	//query在form之前，有值时不再看后面的
+	want := req{Id: 42, Page: 3, Name: "bob", Token: "abc", Sid: "s1", Size: 20, Lang: "zh"}
+	if got != want {
+		t.Errorf("Bind = %+v, want %+v", got, want)
+	}
+}
+
+func TestBindTypes(t *testing.T) {
+	type req struct {
+		S     string        `query:"s"`
+		B     bool          `query:"b"`
+		I8    int8          `query:"i8"`
+		U16   uint16        `query:"u16"`
+		F     float64       `query:"f"`
+		F32   float32       `query:"f32"`
+		T     time.Time     `query:"t"`
+		Day   time.Time     `query:"day" time_format:"2006-01-02"`
+		D     time.Duration `query:"d"`
+		Ints  []int         `query:"n"`
+		Raw   []byte        `query:"raw"`
+		P     *int          `query:"p"`
+		Empty *int          `query:"empty"`
+	}
+	ctx := newFormContext("/x?s=hi&b=true&i8=-12&u16=65535&f=1.5&f32=2.25&t=2026-01-02T03:04:05Z&day=2026-10-17&d=1m30s&n=1&n=2&n=3&raw=xyz&p=7", "")
+	var got req
+	if err := ctx.Input.Bind(&got); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	p := 7
+	want := req{
+		S:    "hi",
+		B:    true,
+		I8:   -12,
+		U16:  65535,
+		F:    1.5,
+		F32:  2.25,
+		T:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Day:  time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local),
+		D:    90 * time.Second,
+		Ints: []int{1, 2, 3},
+		Raw:  []byte("xyz"),
+		P:    &p,
+	}
+	if !got.T.Equal(want.T) || !got.Day.Equal(want.Day) {
+		t.Errorf("time = %v %v, want %v %v", got.T, got.Day, want.T, want.Day)
+	}
+	got.T, got.Day, want.T, want.Day = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Bind = %+v, want %+v", got, want)
+	}
+}
+
+func TestBindTypeErrors(t *testing.T) {
+	type req struct {
+		B    bool           `query:"b"`
+		I8   int8           `query:"i8"`
+		U    uint           `query:"u"`
+		F    float64        `query:"f"`
+		T    time.Time      `query:"t"`
+		D    time.Duration  `query:"d"`
+		Ints []int          `query:"n"`
+		M    map[string]int `query:"m"`
+		Ok   int            `query:"ok" validate:"min=1"`
+	}
+	ctx := newFormContext("/x?b=yes&i8=300&u=-1&f=x&t=2026&d=3&n=1&n=x&m=1&ok=0", "")
+	var got req
+	err := ctx.Input.Bind(&got)
+	var bes BindErrors
+	if !errors.As(err, &bes) {
+		t.Fatalf("Bind error = %v, want BindErrors", err)
+	}
+	if bes.StatusCode() != http.StatusBadRequest || ErrorStatus(err) != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", bes.StatusCode())
+	}
+	//所有字段的错误都要返回
+	for _, f := range []string{"B", "I8", "U", "F", "T", "D", "Ints", "M"} {
+		if fe := bes.Field(f); fe == nil || fe.Rule != "type" {
+			t.Errorf("field %s error = %v, want type error", f, fe)
+		}
+	}
+	if fe := bes.Field("Ok"); fe == nil || fe.Rule != "min" {
+		t.Errorf("field Ok error = %v, want min error", fe)
+	}
+	if len(bes) != 9 {
+		t.Errorf("got %d errors, want 9: %v", len(bes), err)
+	}
+}
+
+func TestBindNested(t *testing.T) {
+	type Paging struct {
+		Page int `query:"page" validate:"min=1"`
+		Size int `query:"size"`
+	}
+	type Filter struct {
+		Tag string `query:"tag" validate:"required"`
+	}
+	type req struct {
+		Paging
+		Filter *Filter
+		Extra  *Filter
+		Opt    *Paging
+	}
+	ctx := newFormContext("/x?page=2&size=10&tag=go", "")
+	var got req
+	got.Extra = &Filter{}
+	if err := ctx.Input.Bind(&got); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	if got.Page != 2 || got.Size != 10 {
+		t.Errorf("embedded = %+v", got.Paging)
+	}
+	//nil的结构体指针在有值时才新建
+	if got.Filter == nil || got.Filter.Tag != "go" {
+		t.Errorf("Filter = %+v", got.Filter)
+	}
+	if got.Extra.Tag != "go" {
+		t.Errorf("Extra = %+v", got.Extra)
+	}
+	if got.Opt == nil || got.Opt.Page != 2 {
+		t.Errorf("Opt = %+v", got.Opt)
+	}
+
+	type emptyReq struct {
+		Filter *Filter
+	}
+	var empty emptyReq
+	if err := newFormContext("/x", "").Input.Bind(&empty); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	if empty.Filter != nil {
+		t.Errorf("Filter = %+v, want nil", empty.Filter)
+	}
+
+	//嵌套的结构体也要校验
+	var bad req
+	err := newFormContext("/x?page=0", "").Input.Bind(&bad)
+	if bes, ok := err.(BindErrors); !ok || bes.Field("Page") == nil {
+		t.Errorf("Bind error = %v, want Page error", err)
+	}
+}
+
+func TestBindFiles(t *testing.T) {
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	mw.WriteField("title", "hello")
+	for _, name := range []string{"a.txt", "b.txt"} {
+		fw, _ := mw.CreateFormFile("files", name)
+		fw.Write([]byte("content of " + name))
+	}
+	fw, _ := mw.CreateFormFile("avatar", "me.png")
+	fw.Write([]byte("png"))
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	ctx, _ := newTestContext(req)
+	if err := ctx.Input.ParseFormOrMulitForm(defaultMaxMemory); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Title  string                  `form:"title" validate:"required"`
+		Avatar *multipart.FileHeader   `form:"avatar"`
+		Files  []*multipart.FileHeader `form:"files"`
+		None   *multipart.FileHeader   `form:"none"`
+	}
+	if err := ctx.Input.Bind(&got); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	if got.Title != "hello" || got.Avatar == nil || got.Avatar.Filename != "me.png" || got.None != nil {
+		t.Errorf("Bind = %+v", got)
+	}
+	if len(got.Files) != 2 || got.Files[0].Filename != "a.txt" || got.Files[1].Filename != "b.txt" {
+		t.Errorf("Files = %v", got.Files)
+	}
+}
+
+func TestBindInvalidDst(t *testing.T) {
+	ctx := newFormContext("/x", "")
+	var s struct{}
+	var n int
+	for _, dst := range []interface{}{s, &n, nil, (*struct{})(nil)} {
+		if err := ctx.Input.Bind(dst); err == nil {
+			t.Errorf("Bind(%T) should fail", dst)
+		}
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	one := 1
+	zero := 0
+	tests := []struct {
+		name  string
+		value interface{}
+		rules string
+		fail  string //没通过的规则，为空表示通过
+	}{
+		{"required ok", "a", "required", ""},
+		{"required empty", "", "required", "required"},
+		{"required zero int", 0, "required", "required"},
+		{"required nil ptr", (*int)(nil), "required", "required"},
+		{"required zero ptr", &zero, "required", ""},
+		{"omitempty zero ptr", &zero, "omitempty,min=1", "min"},
+		{"omitempty skips", "", "omitempty,len=3", ""},
+		{"omitempty checks", "ab", "omitempty,len=3", "len"},
+		{"zero checked", 0, "min=1", "min"},
+		{"empty string checked", "", "min=1", "min"},
+		{"nil ptr skipped", (*int)(nil), "min=1", ""},
+		{"ptr checked", &zero, "min=1", "min"},
+		{"ptr ok", &one, "min=1", ""},
+		{"min ok", 5, "min=1", ""},
+		{"min fail", -1, "min=0", "min"},
+		{"max ok", 100, "max=100", ""},
+		{"max fail", 101, "max=100", "max"},
+		{"uint max", uint8(200), "max=100", "max"},
+		{"float min", 0.5, "min=1", "min"},
+		{"float max", 1.5, "max=1.5", ""},
+		{"string min length", "你好", "min=2", ""},
+		{"string max length", "你好啊", "max=2", "max"},
+		{"slice len", []int{1, 2}, "len=2", ""},
+		{"slice len fail", []int{1}, "len=2", "len"},
+		{"map max", map[string]int{"a": 1, "b": 2}, "max=1", "max"},
+		{"len string", "abc", "len=3", ""},
+		{"oneof ok", "b", "oneof=a b c", ""},
+		{"oneof fail", "d", "oneof=a b c", "oneof"},
+		{"oneof int", 2, "oneof=1 2", ""},
+		{"email ok", "a.b@example.com", "email", ""},
+		{"email fail", "a@b", "email", "email"},
+		{"email spaces", "a b@example.com", "email", "email"},
+		{"regex ok", "abc", "regex=^[a-z]+$", ""},
+		{"regex fail", "ab1", "regex=^[a-z]+$", "regex"},
+		{"regex with comma", "aaa", "min=1,regex=^a{1,3}$", ""},
+		{"regex with comma fail", "aaaa", "regex=^a{1,3}$", "regex"},
+		{"bad regex", "a", "regex=(", "regex"},
+		{"bad limit", 1, "min=x", "min"},
+		{"unknown rule", "a", "foo", "foo"},
+		{"multiple rules", "abcdef", "required,min=2,max=5", "max"},
+	}
+	for _, tt := range tests {
+		sf := reflect.StructField{Name: "F"}
+		fe := validateField(sf.Name, reflect.ValueOf(tt.value), tt.rules)
+		got := ""
+		if fe != nil {
+			got = fe.Rule
+		}
+		if got != tt.fail {
+			t.Errorf("%s: validate %v with %q failed rule %q, want %q", tt.name, tt.value, tt.rules, got, tt.fail)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	type Inner struct {
+		Code string `validate:"len=2"`
+	}
+	type Other struct {
+		Level int `validate:"max=3"`
+	}
+	type req struct {
+		Name  string `validate:"required"`
+		Age   int    `validate:"min=18"`
+		Inner Inner
+		Opt   *Other
+		Skip  string `validate:"-"`
+	}
+	err := Validate(&req{Name: "a", Age: 18, Inner: Inner{Code: "cn"}, Opt: &Other{}})
+	if err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	err = Validate(req{Age: 3, Inner: Inner{Code: "x"}, Opt: &Other{Level: 5}})
+	bes, ok := err.(BindErrors)
+	if !ok || len(bes) != 4 {
+		t.Fatalf("Validate error = %v, want 4 errors", err)
+	}
+	for _, f := range []string{"Name", "Age", "Code", "Level"} {
+		if bes.Field(f) == nil {
+			t.Errorf("missing error for %s", f)
+		}
+	}
+	if err := Validate(1); err == nil {
+		t.Error("Validate(1) should fail")
+	}
+}
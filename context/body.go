@@ -0,0 +1,226 @@
+package context
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+/**
+请求body的解码函数
+	r：请求的body，已经限制了大小并处理过gzip
+	dst：要解码到的对象，一般为结构体指针
+	strict：是否不允许有dst里没有的字段，不支持的解码函数可以忽略
+*/
+type BodyDecoder func(r io.Reader, dst interface{}, strict bool) error
+
+//按Content-Type注册的解码函数
+var bodyDecoders = struct {
+	sync.RWMutex
+	m map[string]BodyDecoder
+}{m: map[string]BodyDecoder{
+	"application/json": decodeJsonBody,
+	"text/json":        decodeJsonBody,
+	"application/xml":  decodeXmlBody,
+	"text/xml":         decodeXmlBody,
+}}
+
+/**
+注册某个Content-Type的解码函数，如"application/msgpack"，已有的会被覆盖
+表单及上传文件按form标签解码，不走这里
+*/
+func RegisterBodyDecoder(contentType string, fn BodyDecoder) {
+	bodyDecoders.Lock()
+	defer bodyDecoders.Unlock()
+	bodyDecoders.m[strings.ToLower(contentType)] = fn
+}
+
+//按Content-Type提取解码函数，"application/xxx+json"这类会按后缀查找
+func lookupBodyDecoder(mediaType string) BodyDecoder {
+	bodyDecoders.RLock()
+	defer bodyDecoders.RUnlock()
+	if fn, ok := bodyDecoders.m[mediaType]; ok {
+		return fn
+	}
+	if pos := strings.LastIndex(mediaType, "+"); pos >= 0 {
+		if fn, ok := bodyDecoders.m["application/"+mediaType[pos+1:]]; ok {
+			return fn
+		}
+	}
+	return nil
+}
+
+func decodeJsonBody(r io.Reader, dst interface{}, strict bool) error {
+	dec := json.NewDecoder(r)
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(dst); err != nil {
+		return err
+	}
+	//body里只能有一个json值
+	if dec.More() {
+		return NewHTTPError(http.StatusBadRequest, "unexpected data after json value")
+	}
+	return nil
+}
+
+func decodeXmlBody(r io.Reader, dst interface{}, strict bool) error {
+	return xml.NewDecoder(r).Decode(dst)
+}
+
+/**
+按Content-Type把请求body解码到dst里，解码后按validate标签校验
+	json、xml：用注册的解码函数，可通过RegisterBodyDecoder扩展
+	x-www-form-urlencoded、multipart/form-data：按form标签填充，同Bind
+
+是否严格模式取ThingoConfig.StrictBody
+返回的错误：
+
+	415：不支持的Content-Type
+	413：body超过了ThingoConfig.MaxMemory
+	400：body格式有误，校验不通过时为BindErrors
+*/
+func (input *ThingoInput) DecodeBody(dst interface{}) error {
+	return input.decodeBody(dst, input.Context.Config != nil && input.Context.Config.StrictBody)
+}
+
+//同DecodeBody，不允许body里有dst里没有的字段
+func (input *ThingoInput) DecodeBodyStrict(dst interface{}) error {
+	return input.decodeBody(dst, true)
+}
+
+func (input *ThingoInput) decodeBody(dst interface{}, strict bool) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return NewHTTPError(http.StatusInternalServerError, "decode body: dst must be a non-nil pointer, got %T", dst)
+	}
+	ct := input.Header("Content-Type")
+	if ct == "" {
+		return NewHTTPError(http.StatusUnsupportedMediaType, "missing Content-Type")
+	}
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return &ThingoHTTPError{Status: http.StatusUnsupportedMediaType, Msg: "invalid Content-Type", Err: err}
+	}
+	mediaType = strings.ToLower(mediaType)
+
+	//表单直接按form标签填充
+	if mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data" {
+		return input.decodeForm(dst, strict)
+	}
+
+	fn := lookupBodyDecoder(mediaType)
+	if fn == nil {
+		return NewHTTPError(http.StatusUnsupportedMediaType, "unsupported Content-Type %q", mediaType)
+	}
+	body, err := input.readBody()
+	if err != nil {
+		return err
+	}
+	if len(body) == 0 {
+		return NewHTTPError(http.StatusBadRequest, "empty request body")
+	}
+	if err := fn(bytes.NewReader(body), dst, strict); err != nil {
+		if _, ok := err.(interface{ StatusCode() int }); ok {
+			return err
+		}
+		return &ThingoHTTPError{Status: http.StatusBadRequest, Msg: "invalid " + mediaType + " body", Err: err}
+	}
+	if elem := reflect.Indirect(rv); elem.Kind() == reflect.Struct {
+		return Validate(dst)
+	}
+	return nil
+}
+
+//按form标签填充表单，严格模式下表单里不能有没有对应字段的参数
+func (input *ThingoInput) decodeForm(dst interface{}, strict bool) error {
+	req := input.Context.Request
+	//处理请求时已经解析过的，这里返回当时的错误
+	if err := input.ParseFormOrMulitForm(input.maxMemory()); err != nil {
+		return err
+	}
+	if strict {
+		known := make(map[string]bool)
+		collectFormKeys(reflect.Indirect(reflect.ValueOf(dst)).Type(), known)
+		for k := range req.PostForm {
+			if !known[k] {
+				return NewHTTPError(http.StatusBadRequest, "unknown form field %q", k)
+			}
+		}
+		if req.MultipartForm != nil {
+			for k := range req.MultipartForm.File {
+				if !known[k] {
+					return NewHTTPError(http.StatusBadRequest, "unknown form field %q", k)
+				}
+			}
+		}
+	}
+	return input.Bind(dst)
+}
+
+//提取结构体里所有的form标签
+func collectFormKeys(rt reflect.Type, keys map[string]bool) {
+	if rt.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if key, ok := sf.Tag.Lookup("form"); ok && key != "-" {
+			keys[key] = true
+		} else if sf.Type.Kind() == reflect.Struct {
+			collectFormKeys(sf.Type, keys)
+		}
+	}
+}
+
+/**
+读取请求body，最多ThingoConfig.MaxMemory个字节，超过时返回413
+读取后会放回Request.Body及RequestBody里，可以重复读取
+*/
+func (input *ThingoInput) readBody() ([]byte, error) {
+	if len(input.RequestBody) > 0 {
+		return input.RequestBody, nil
+	}
+	req := input.Context.Request
+	if req.Body == nil {
+		return []byte{}, nil
+	}
+	limit := input.maxMemory()
+	var reader io.Reader = &io.LimitedReader{R: req.Body, N: limit + 1}
+	if input.Header("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, &ThingoHTTPError{Status: http.StatusBadRequest, Msg: "invalid gzip body", Err: err}
+		}
+		defer gz.Close()
+		reader = io.LimitReader(gz, limit+1)
+	}
+	body, err := ioutil.ReadAll(reader)
+	req.Body.Close()
+	if err != nil {
+		return nil, &ThingoHTTPError{Status: http.StatusBadRequest, Msg: "read request body failed", Err: err}
+	}
+	if int64(len(body)) > limit {
+		return nil, NewHTTPError(http.StatusRequestEntityTooLarge, "request body exceeds %d bytes", limit)
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	input.RequestBody = body
+	return body, nil
+}
+
+//解析表单、读取body时的大小上限
+func (input *ThingoInput) maxMemory() int64 {
+	if cfg := input.Context.Config; cfg != nil && cfg.MaxMemory > 0 {
+		return cfg.MaxMemory
+	}
+	return defaultMaxMemory
+}
@@ -0,0 +1,95 @@
+package context
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testBody struct {
+	Name string `form:"name" json:"name" validate:"required"`
+	Age  int    `form:"age" json:"age"`
+}
+
+//新建一个multipart表单的body
+func multipartBody(fields map[string]string) (string, string) {
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	mw.Close()
+	return body.String(), mw.FormDataContentType()
+}
+
+func TestDecodeBody(t *testing.T) {
+	mp, mpType := multipartBody(map[string]string{"name": "tom", "age": "3"})
+	bigMp, bigMpType := multipartBody(map[string]string{"name": strings.Repeat("x", 1000)})
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		maxMemory   int64
+		status      int //期望的错误状态码，0为成功
+	}{
+		{"json", "application/json", `{"name":"tom","age":3}`, 0, 0},
+		{"json invalid", "application/json", `{"name":`, 0, http.StatusBadRequest},
+		{"json too large", "application/json", `{"name":"` + strings.Repeat("x", 1000) + `"}`, 10, http.StatusRequestEntityTooLarge},
+		{"form", "application/x-www-form-urlencoded", "name=tom&age=3", 0, 0},
+		{"form invalid", "application/x-www-form-urlencoded", "name=%zz&age=3", 0, http.StatusBadRequest},
+		{"form too large", "application/x-www-form-urlencoded", "name=" + strings.Repeat("x", 1000), 10, http.StatusRequestEntityTooLarge},
+		{"form required", "application/x-www-form-urlencoded", "age=3", 0, http.StatusBadRequest},
+		{"multipart", mpType, mp, 0, 0},
+		{"multipart truncated", mpType, mp[:len(mp)/2], 0, http.StatusBadRequest},
+		{"multipart no boundary", "multipart/form-data", mp, 0, http.StatusBadRequest},
+		{"multipart too large", bigMpType, bigMp, 100, http.StatusRequestEntityTooLarge},
+		{"unsupported", "text/plain", "name=tom", 0, http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		ctx, _ := newTestContext(req)
+		ctx.Config = NewThingoConfig()
+		if tt.maxMemory > 0 {
+			ctx.Config.MaxMemory = tt.maxMemory
+		}
+		var got testBody
+		err := ctx.Input.DecodeBody(&got)
+		if tt.status == 0 {
+			if err != nil || got.Name != "tom" || got.Age != 3 {
+				t.Errorf("%s: got %+v, %v", tt.name, got, err)
+			}
+			continue
+		}
+		if err == nil || ErrorStatus(err) != tt.status {
+			t.Errorf("%s: err = %v, want status %d", tt.name, err, tt.status)
+		}
+	}
+}
+
+//处理请求时已经解析过表单，DecodeBody返回当时的错误，而不是校验失败
+func TestDecodeBodyParsedForm(t *testing.T) {
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{"name=%zz", http.StatusBadRequest},
+		{"name=" + strings.Repeat("x", 1000), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ctx, _ := newTestContext(req)
+		ctx.Config = NewThingoConfig()
+		ctx.Config.MaxMemory = 10
+		ctx.Input.ParseFormOrMulitForm(ctx.Config.MaxMemory)
+		var got testBody
+		err := ctx.Input.DecodeBody(&got)
+		if _, ok := err.(BindErrors); ok || ErrorStatus(err) != tt.status {
+			t.Errorf("%q: err = %v, want status %d", tt.body, err, tt.status)
+		}
+	}
+}
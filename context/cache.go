@@ -0,0 +1,191 @@
+package context
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//自动生成ETag的方式
+const (
+	ETagNone   = iota //不自动生成
+	ETagWeak          //按body的长度及哈希生成弱ETag，如 W/"1a-5d3f..."
+	ETagStrong        //按body的SHA1生成强ETag
+)
+
+/**
+缓存策略，对应Cache-Control里的各个指令，时长按秒输出，为0时不输出
+	Public/Private：能否被代理等共享缓存缓存
+	NoCache：每次都要回源验证，NoStore：不能缓存
+	MaxAge：浏览器缓存的时长，需要“max-age=0”时用NoCache
+	SMaxAge：共享缓存的时长
+	Immutable：有效期内内容不会变，刷新时也不用验证
+	StaleWhileRevalidate：过期后还可以先用旧的，同时在后台验证
+	StaleIfError：回源出错时还可以用旧的
+*/
+type CachePolicy struct {
+	Public               bool
+	Private              bool
+	NoCache              bool
+	NoStore              bool
+	MustRevalidate       bool
+	MaxAge               time.Duration
+	SMaxAge              time.Duration
+	Immutable            bool
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+}
+
+//默认的缓存策略，和之前写死的头信息一样：“no-cache, must-revalidate”，同时带“Expires: 0”
+var DefaultCachePolicy = CachePolicy{NoCache: true, MustRevalidate: true}
+
+//转为Cache-Control的值
+func (p CachePolicy) String() string {
+	var ds []string
+	if p.Public {
+		ds = append(ds, "public")
+	}
+	if p.Private {
+		ds = append(ds, "private")
+	}
+	if p.NoCache {
+		ds = append(ds, "no-cache")
+	}
+	if p.NoStore {
+		ds = append(ds, "no-store")
+	}
+	if p.MustRevalidate {
+		ds = append(ds, "must-revalidate")
+	}
+	seconds := func(name string, d time.Duration) {
+		if d > 0 {
+			ds = append(ds, name+"="+strconv.FormatInt(int64(d/time.Second), 10))
+		}
+	}
+	seconds("max-age", p.MaxAge)
+	seconds("s-maxage", p.SMaxAge)
+	if p.Immutable {
+		ds = append(ds, "immutable")
+	}
+	seconds("stale-while-revalidate", p.StaleWhileRevalidate)
+	seconds("stale-if-error", p.StaleIfError)
+	return strings.Join(ds, ", ")
+}
+
+/**
+设置本次响应的缓存策略，替换掉默认的
+不能缓存时同时输出“Expires: 0”，兼容只认Expires的旧客户端，否则去掉Expires
+*/
+func (output *ThingoOuput) SetCachePolicy(p CachePolicy) {
+	header := output.Context.ResponseWriter.Header()
+	if v := p.String(); v != "" {
+		header.Set("Cache-Control", v)
+	} else {
+		header.Del("Cache-Control")
+	}
+	if p.NoCache || p.NoStore {
+		header.Set("Expires", "0")
+	} else {
+		header.Del("Expires")
+	}
+}
+
+//设置ETag，tag不用带引号，weak为true时为弱ETag
+func (output *ThingoOuput) SetETag(tag string, weak bool) {
+	etag := strconv.Quote(tag)
+	if weak {
+		etag = "W/" + etag
+	}
+	output.AddHeader("ETag", etag)
+}
+
+//设置Last-Modified，按秒精度输出
+func (output *ThingoOuput) SetLastModified(t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	output.AddHeader("Last-Modified", t.UTC().Format(http.TimeFormat))
+}
+
+/**
+按已设置的ETag、Last-Modified判断客户端的缓存是否还有效，有效时状态码置为304并清空body
+只处理GET、HEAD请求及200的响应，If-None-Match优先，没有时才看If-Modified-Since
+控制层可以在生成body之前先设置好ETag等再调用，返回true时就不用再生成了，Send时也会自动调用
+*/
+func (output *ThingoOuput) NotModified() bool {
+	req := output.Context.Request
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if output.Status != 0 && output.Status != http.StatusOK {
+		return false
+	}
+	header := output.Context.ResponseWriter.Header()
+	fresh := false
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		fresh = etagMatch(inm, header.Get("ETag"))
+	} else if ims := req.Header.Get("If-Modified-Since"); ims != "" {
+		lm, err := http.ParseTime(header.Get("Last-Modified"))
+		since, err2 := http.ParseTime(ims)
+		fresh = err == nil && err2 == nil && !lm.Truncate(time.Second).After(since)
+	}
+	if !fresh {
+		return false
+	}
+	output.Status = http.StatusNotModified
+	output.Body = nil
+	//304不能带描述body的头信息
+	for _, k := range []string{"Content-Type", "Content-Length", "Content-Encoding", "Content-Disposition"} {
+		header.Del(k)
+	}
+	return true
+}
+
+//If-None-Match里是否有和etag弱比较相同的，“*”表示任意
+func etagMatch(inm, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(inm, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+//按配置给暂存的body生成ETag，已设置过的不覆盖，只处理GET、HEAD请求的200响应
+func (output *ThingoOuput) autoETag() {
+	mode := ETagNone
+	if output.Context.Config != nil {
+		mode = output.Context.Config.ETag
+	}
+	if mode == ETagNone || len(output.Body) == 0 {
+		return
+	}
+	if m := output.Context.Request.Method; m != http.MethodGet && m != http.MethodHead {
+		return
+	}
+	if output.Status != 0 && output.Status != http.StatusOK {
+		return
+	}
+	header := output.Context.ResponseWriter.Header()
+	if header.Get("ETag") != "" {
+		return
+	}
+	if mode == ETagStrong {
+		sum := sha1.Sum(output.Body)
+		header.Set("ETag", `"`+base64.RawURLEncoding.EncodeToString(sum[:])+`"`)
+		return
+	}
+	h := fnv.New64a()
+	h.Write(output.Body)
+	header.Set("ETag", fmt.Sprintf(`W/"%x-%x"`, len(output.Body), h.Sum64()))
+}
@@ -0,0 +1,213 @@
+package context
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAutoETag(t *testing.T) {
+	body := []byte("hello thingo")
+	h := fnv.New64a()
+	h.Write(body)
+	weak := fmt.Sprintf(`W/"%x-%x"`, len(body), h.Sum64())
+	sum := sha1.Sum(body)
+	strong := `"` + base64.RawURLEncoding.EncodeToString(sum[:]) + `"`
+
+	tests := []struct {
+		name   string
+		mode   int
+		method string
+		status int
+		body   []byte
+		etag   string //已经设置的ETag
+		want   string
+	}{
+		{"weak", ETagWeak, "GET", 0, body, "", weak},
+		{"strong", ETagStrong, "GET", 0, body, "", strong},
+		{"none", ETagNone, "GET", 0, body, "", ""},
+		{"head", ETagWeak, "HEAD", 0, body, "", weak},
+		{"status 200", ETagWeak, "GET", http.StatusOK, body, "", weak},
+		{"post", ETagWeak, "POST", 0, body, "", ""},
+		{"not found", ETagWeak, "GET", http.StatusNotFound, body, "", ""},
+		{"empty body", ETagWeak, "GET", 0, nil, "", ""},
+		{"already set", ETagStrong, "GET", 0, body, `"mine"`, `"mine"`},
+	}
+	for _, tt := range tests {
+		ctx, rec := newTestContext(httptest.NewRequest(tt.method, "/", nil))
+		ctx.Config.ETag = tt.mode
+		ctx.Output.SetStatus(tt.status)
+		ctx.Output.SetBody(tt.body)
+		if tt.etag != "" {
+			rec.Header().Set("ETag", tt.etag)
+		}
+		ctx.Output.Send()
+		if got := rec.Header().Get("ETag"); got != tt.want {
+			t.Errorf("%s: ETag = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+//同样的body生成同样的ETag，不同的body不同
+func TestAutoETagStable(t *testing.T) {
+	etag := func(body string) string {
+		ctx, rec := newTestContext(httptest.NewRequest("GET", "/", nil))
+		ctx.Output.SetBody([]byte(body))
+		ctx.Output.Send()
+		return rec.Header().Get("ETag")
+	}
+	if a, b := etag("abc"), etag("abc"); a != b {
+		t.Errorf("same body: %q != %q", a, b)
+	}
+	if a, b := etag("abc"), etag("abd"); a == b {
+		t.Errorf("different body: both %q", a)
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	lm := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	before := lm.Add(-time.Hour).Format(http.TimeFormat)
+	after := lm.Add(time.Hour).Format(http.TimeFormat)
+	at := lm.Format(http.TimeFormat)
+
+	tests := []struct {
+		name   string
+		method string
+		status int
+		etag   string
+		lm     time.Time //响应的Last-Modified
+		inm    string    //If-None-Match
+		ims    string    //If-Modified-Since
+		want   bool
+	}{
+		{"strong match", "GET", 0, `"v1"`, time.Time{}, `"v1"`, "", true},
+		{"mismatch", "GET", 0, `"v1"`, time.Time{}, `"v2"`, "", false},
+		//弱比较，W/前缀不影响
+		{"weak request", "GET", 0, `"v1"`, time.Time{}, `W/"v1"`, "", true},
+		{"weak response", "GET", 0, `W/"v1"`, time.Time{}, `"v1"`, "", true},
+		{"both weak", "GET", 0, `W/"v1"`, time.Time{}, `W/"v1"`, "", true},
+		{"list", "GET", 0, `"v1"`, time.Time{}, `"a", W/"b", "v1"`, "", true},
+		{"list mismatch", "GET", 0, `"v1"`, time.Time{}, `"a", "b"`, "", false},
+		{"star", "GET", 0, `"v1"`, time.Time{}, `*`, "", true},
+		{"no etag", "GET", 0, "", time.Time{}, `"v1"`, "", false},
+		//有If-None-Match时忽略If-Modified-Since
+		{"inm mismatch wins", "GET", 0, `"v1"`, lm, `"v2"`, after, false},
+		{"inm match wins", "GET", 0, `"v1"`, lm, `"v1"`, before, true},
+		{"ims equal", "GET", 0, "", lm, "", at, true},
+		{"ims after", "GET", 0, "", lm, "", after, true},
+		{"ims before", "GET", 0, "", lm, "", before, false},
+		//Last-Modified按秒比较
+		{"ims subsecond", "GET", 0, "", lm.Add(500 * time.Millisecond), "", at, true},
+		{"ims invalid", "GET", 0, "", lm, "", "yesterday", false},
+		{"ims no last-modified", "GET", 0, "", time.Time{}, "", after, false},
+		{"head", "HEAD", 0, `"v1"`, time.Time{}, `"v1"`, "", true},
+		{"post", "POST", 0, `"v1"`, time.Time{}, `"v1"`, "", false},
+		{"status 200", "GET", http.StatusOK, `"v1"`, time.Time{}, `"v1"`, "", true},
+		{"status 201", "GET", http.StatusCreated, `"v1"`, time.Time{}, `"v1"`, "", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/", nil)
+		if tt.inm != "" {
+			req.Header.Set("If-None-Match", tt.inm)
+		}
+		if tt.ims != "" {
+			req.Header.Set("If-Modified-Since", tt.ims)
+		}
+		ctx, rec := newTestContext(req)
+		ctx.Config.ETag = ETagNone
+		ctx.Output.SetStatus(tt.status)
+		if tt.etag != "" {
+			rec.Header().Set("ETag", tt.etag)
+		}
+		ctx.Output.SetLastModified(tt.lm)
+		ctx.Output.SetBody([]byte("body"))
+		if got := ctx.Output.NotModified(); got != tt.want {
+			t.Errorf("%s: NotModified = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+//304去掉body及描述body的头信息，保留ETag、缓存相关的头信息
+func TestNotModifiedResponse(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	ctx, rec := newTestContext(req)
+	ctx.Output.AddHeader("Content-Type", "application/json")
+	ctx.Output.AddHeader("Content-Length", "11")
+	ctx.Output.AddHeader("Content-Encoding", "identity")
+	ctx.Output.AddHeader("Content-Disposition", "inline")
+	ctx.Output.AddHeader("Vary", "Accept")
+	ctx.Output.SetCachePolicy(CachePolicy{Private: true, MaxAge: time.Minute})
+	ctx.Output.SetBody([]byte(`{"a":"b"}`))
+	ctx.Output.Send()
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("first response: %d, ETag %q", rec.Code, etag)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-None-Match", etag)
+	ctx, rec = newTestContext(req)
+	ctx.Output.AddHeader("Content-Type", "application/json")
+	ctx.Output.AddHeader("Content-Length", "11")
+	ctx.Output.AddHeader("Content-Encoding", "identity")
+	ctx.Output.AddHeader("Content-Disposition", "inline")
+	ctx.Output.AddHeader("Vary", "Accept")
+	ctx.Output.SetCachePolicy(CachePolicy{Private: true, MaxAge: time.Minute})
+	ctx.Output.AddCookie("sid", "abc")
+	ctx.Output.SetBody([]byte(`{"a":"b"}`))
+	ctx.Output.Send()
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("got %d with %d bytes, want 304 without body", rec.Code, rec.Body.Len())
+	}
+	for _, k := range []string{"Content-Type", "Content-Length", "Content-Encoding", "Content-Disposition"} {
+		if v := rec.Header().Get(k); v != "" {
+			t.Errorf("304 has %s: %q", k, v)
+		}
+	}
+	want := map[string]string{
+		"ETag":          etag,
+		"Cache-Control": "private, max-age=60",
+		"Vary":          "Accept",
+		"Set-Cookie":    "sid=abc; Path=/",
+	}
+	for k, v := range want {
+		if got := rec.Header().Get(k); got != v {
+			t.Errorf("304 %s = %q, want %q", k, got, v)
+		}
+	}
+	if ctx.Writer.Status() != http.StatusNotModified {
+		t.Errorf("Writer.Status() = %d", ctx.Writer.Status())
+	}
+}
+
+func TestCachePolicy(t *testing.T) {
+	tests := []struct {
+		p       CachePolicy
+		want    string
+		expires string
+	}{
+		{DefaultCachePolicy, "no-cache, must-revalidate", "0"},
+		{CachePolicy{NoStore: true}, "no-store", "0"},
+		{CachePolicy{Public: true, MaxAge: time.Hour, SMaxAge: 2 * time.Hour, Immutable: true}, "public, max-age=3600, s-maxage=7200, immutable", ""},
+		{CachePolicy{Private: true, MaxAge: 90 * time.Second, StaleWhileRevalidate: time.Minute, StaleIfError: time.Hour}, "private, max-age=90, stale-while-revalidate=60, stale-if-error=3600", ""},
+		{CachePolicy{}, "", ""},
+	}
+	for _, tt := range tests {
+		if got := tt.p.String(); got != tt.want {
+			t.Errorf("%+v: String() = %q, want %q", tt.p, got, tt.want)
+		}
+		ctx, rec := newTestContext(httptest.NewRequest("GET", "/", nil))
+		ctx.Output.SetCachePolicy(tt.p)
+		if got := rec.Header().Get("Cache-Control"); got != tt.want {
+			t.Errorf("%+v: Cache-Control = %q, want %q", tt.p, got, tt.want)
+		}
+		if got := rec.Header().Get("Expires"); got != tt.expires {
+			t.Errorf("%+v: Expires = %q, want %q", tt.p, got, tt.expires)
+		}
+	}
+}
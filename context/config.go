@@ -0,0 +1,40 @@
+package context
+
+import (
+	"net"
+	"net/http"
+)
+
+//默认的POST最大内存
+const defaultMaxMemory = 64 << 20
+
+//应用级别的配置，由ThingoHandler创建，所有请求的上下文共用同一份，运行时不要修改
+type ThingoConfig struct {
+	MaxMemory      int64        //POST时的最大内存，也是读取请求body的上限
+	StrictBody     bool         //解析请求body时不允许有结构体里没有的字段
+	TrustedProxies []*net.IPNet //信任的代理，见SetTrustedProxies
+	ETag           int          //自动生成ETag的方式，如ETagWeak，默认为弱ETag
+	CachePolicy    *CachePolicy //默认的缓存策略，为nil时不输出Cache-Control
+	Headers        http.Header  //每个响应默认带的头信息，默认为DefaultHeaders()
+}
+
+//返回默认的配置
+func NewThingoConfig() *ThingoConfig {
+	policy := DefaultCachePolicy
+	return &ThingoConfig{
+		MaxMemory:   defaultMaxMemory,
+		ETag:        ETagWeak,
+		CachePolicy: &policy,
+		Headers:     DefaultHeaders(),
+	}
+}
+
+//默认添加到每个响应上的头信息，每次返回新的一份，可以用ThingoConfig.Headers增删
+func DefaultHeaders() http.Header {
+	return http.Header{
+		"Referrer-Policy": {"origin-when-cross-origin"},
+		"X-Powered-By":    {"ThingoGo"},
+		"Server":          {"ThingoGo"},
+		"Github":          {"https://github.com/liuyongshuai/Thingogo"},
+	}
+}
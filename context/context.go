@@ -23,6 +23,8 @@ func NewThingoContext() *ThingoContext {
 	ThingoCtx := &ThingoContext{
 		Input:  NewThingoInput(),
 		Output: NewThingoOutput(),
+		Writer: &ThingoResponseWriter{},
+		Config: NewThingoConfig(),
 	}
 	ThingoCtx.Output.Context = ThingoCtx
 	ThingoCtx.Input.Context = ThingoCtx
@@ -31,17 +33,28 @@ func NewThingoContext() *ThingoContext {
 
 //上下文的定义
 type ThingoContext struct {
-	Input          *ThingoInput        //收到的请求里相关信息，包括参数、方法、上传文件等
-	Output         *ThingoOuput        //要发送给端的暂存用的数据
-	Request        *http.Request       //请求原始对象指针
-	ResponseWriter http.ResponseWriter //响应原始对象
-	UniqueKey      string              //本次请求的唯一标识符
+	Input          *ThingoInput          //收到的请求里相关信息，包括参数、方法、上传文件等
+	Output         *ThingoOuput          //要发送给端的暂存用的数据
+	Request        *http.Request         //请求原始对象指针
+	ResponseWriter http.ResponseWriter   //响应原始对象
+	Writer         *ThingoResponseWriter //包装后的响应对象，记录状态码及写出的字节数
+	UniqueKey      string                //本次请求的唯一标识符
+	PanicValue     interface{}           //处理过程中panic的值，给OnPanic插件用
+	Config         *ThingoConfig         //应用级别的配置
+	CSPNonce       string                //本次请求的CSP nonce，由安全头中间件生成，模板里为CSP_NONCE
+	sse            *ThingoSSE            //SSE输出对象，调用SSE()后才有
+	releaseFuncs   []func()              //请求结束时要执行的清理函数
 }
 
 //重置本次请求的上下文
 func (ThingoCtx *ThingoContext) Reset(rw *http.ResponseWriter, r *http.Request) {
 	ThingoCtx.Request = r
-	ThingoCtx.ResponseWriter = *rw
+	ThingoCtx.Writer.Reset(*rw)
+	ThingoCtx.ResponseWriter = ThingoCtx.Writer
+	ThingoCtx.PanicValue = nil
+	ThingoCtx.CSPNonce = ""
+	ThingoCtx.sse = nil
+	ThingoCtx.releaseFuncs = nil
 	ThingoCtx.Input.Reset(ThingoCtx)
 	ThingoCtx.Output.Reset(ThingoCtx)
 	var nextId int64 = 0
@@ -55,6 +68,20 @@ func (ThingoCtx *ThingoContext) Reset(rw *http.ResponseWriter, r *http.Request)
 	ThingoCtx.UniqueKey = fmt.Sprintf("%x", nextId)
 }
 
+//添加一个请求结束时要执行的清理函数，如关闭SSE、WebSocket连接，按添加的相反顺序执行
+func (ThingoCtx *ThingoContext) OnRelease(fn func()) {
+	ThingoCtx.releaseFuncs = append(ThingoCtx.releaseFuncs, fn)
+}
+
+//请求处理完后释放占用的资源，执行OnRelease添加的清理函数，由ThingoHandler自动调用
+func (ThingoCtx *ThingoContext) Release() {
+	for i := len(ThingoCtx.releaseFuncs) - 1; i >= 0; i-- {
+		ThingoCtx.releaseFuncs[i]()
+	}
+	ThingoCtx.releaseFuncs = nil
+	ThingoCtx.sse = nil
+}
+
 //跳转，状态码可选，默认301
 func (ThingoCtx *ThingoContext) Redirect(locationUrl string, status ...int) {
 	code := http.StatusTemporaryRedirect
@@ -64,11 +91,9 @@ func (ThingoCtx *ThingoContext) Redirect(locationUrl string, status ...int) {
 	http.Redirect(ThingoCtx.ResponseWriter, ThingoCtx.Request, locationUrl, code)
 }
 
-//刷新返回数据
+//刷新返回数据，Output会转为流式输出，暂存的头信息及body会先发出去
 func (ThingoCtx *ThingoContext) Flush() {
-	if f, ok := ThingoCtx.ResponseWriter.(http.Flusher); ok {
-		f.Flush()
-	}
+	ThingoCtx.Output.Flush()
 }
 
 //当客户端取消请求或连接断开时用
@@ -0,0 +1,15 @@
+package context
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+//按请求新建一个上下文，返回的recorder里是最终的响应
+func newTestContext(req *http.Request) (*ThingoContext, *httptest.ResponseRecorder) {
+	rec := httptest.NewRecorder()
+	var rw http.ResponseWriter = rec
+	ctx := NewThingoContext()
+	ctx.Reset(&rw, req)
+	return ctx, rec
+}
@@ -0,0 +1,153 @@
+package context
+
+import (
+	"encoding"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+/**
+逐行产生csv数据的迭代函数，没有更多的行时返回io.EOF
+	rows := func() ([]string, error) {
+		if !it.Next() {
+			return nil, io.EOF
+		}
+		return it.Row(), nil
+	}
+*/
+type CsvRowFunc func() ([]string, error)
+
+/**
+输出csv，支持的数据：
+	[][]string、[][]interface{}等二维切片
+	结构体切片：第一行为表头，列名依次取csv、json标签，都没有时用字段名
+	CsvRowFunc、<-chan []string：逐行输出，不用先把所有的行放到内存里
+*/
+type csvRenderer struct{}
+
+func (csvRenderer) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+func (csvRenderer) Render(w io.Writer, data interface{}) error {
+	cw := csv.NewWriter(w)
+	if err := writeCsvRows(cw, data); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+//按数据的类型逐行写入
+func writeCsvRows(cw *csv.Writer, data interface{}) error {
+	switch rows := data.(type) {
+	case [][]string:
+		return cw.WriteAll(rows)
+	case CsvRowFunc:
+		return writeCsvIter(cw, rows)
+	case func() ([]string, error):
+		return writeCsvIter(cw, rows)
+	case <-chan []string:
+		for row := range rows {
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return fmt.Errorf("csv: unsupported type %T", data)
+	}
+	headerDone := false
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		for item.Kind() == reflect.Ptr || item.Kind() == reflect.Interface {
+			item = item.Elem()
+		}
+		var row []string
+		switch item.Kind() {
+		case reflect.Struct:
+			fields := structFields(item, "csv", func(s string) string { return s })
+			if !headerDone {
+				header := make([]string, len(fields))
+				for j, f := range fields {
+					header[j] = f.key
+				}
+				if err := cw.Write(header); err != nil {
+					return err
+				}
+				headerDone = true
+			}
+			for _, f := range fields {
+				row = append(row, csvCell(f.val))
+			}
+		case reflect.Slice, reflect.Array:
+			for j := 0; j < item.Len(); j++ {
+				row = append(row, csvCell(item.Index(j)))
+			}
+		default:
+			return fmt.Errorf("csv: unsupported row type %s", item.Type())
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//逐行取迭代函数里的数据
+func writeCsvIter(cw *csv.Writer, next func() ([]string, error)) error {
+	for {
+		row, err := next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+}
+
+//单元格的值
+func csvCell(v reflect.Value) string {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return ""
+	}
+	switch val := v.Interface().(type) {
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.Format(time.RFC3339)
+	case encoding.TextMarshaler:
+		if b, err := val.MarshalText(); err == nil {
+			return string(b)
+		}
+	case fmt.Stringer:
+		return val.String()
+	}
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits())
+	}
+	return fmt.Sprint(v.Interface())
+}
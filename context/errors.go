@@ -0,0 +1,44 @@
+package context
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+//带HTTP状态码的错误
+type ThingoHTTPError struct {
+	Status int    //HTTP状态码
+	Msg    string //错误描述
+	Err    error  //原始的错误，可以为nil
+}
+
+//新建一个带状态码的错误
+func NewHTTPError(status int, format string, args ...interface{}) *ThingoHTTPError {
+	return &ThingoHTTPError{Status: status, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *ThingoHTTPError) Error() string {
+	if e.Err != nil {
+		return e.Msg + ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+//对应的HTTP状态码
+func (e *ThingoHTTPError) StatusCode() int {
+	return e.Status
+}
+
+func (e *ThingoHTTPError) Unwrap() error {
+	return e.Err
+}
+
+//提取错误对应的HTTP状态码，错误没有实现StatusCode()时为500
+func ErrorStatus(err error) int {
+	var se interface{ StatusCode() int }
+	if errors.As(err, &se) {
+		return se.StatusCode()
+	}
+	return http.StatusInternalServerError
+}
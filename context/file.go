@@ -0,0 +1,126 @@
+package context
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/**
+输出一段内容，由http.ServeContent处理：
+	Range请求，多个区间时为multipart/byteranges
+	If-Modified-Since、If-None-Match等条件请求，ETag要在调用前用AddHeader设置
+	按name的扩展名或内容检测Content-Type，调用前设置过的不会被覆盖
+
+调用后Output即为已开始输出，之前设置的状态码及body不再生效
+*/
+func (output *ThingoOuput) ServeContent(name string, modtime time.Time, content io.ReadSeeker) {
+	output.addCookieHeaders()
+	output.Started = true
+	output.Streaming = true
+	output.Body = nil
+	output.Status = 0
+	http.ServeContent(output.Context.ResponseWriter, output.Context.Request, name, modtime, content)
+}
+
+/**
+输出本地的文件，支持的功能见ServeContent，会按文件的大小及修改时间生成弱ETag
+path由调用方负责校验，不要直接使用客户端传过来的路径
+文件不存在或是目录时状态码为404，没有权限时为403，并返回对应的错误
+*/
+func (output *ThingoOuput) ServeFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return output.fileError(path, err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return output.fileError(path, err)
+	}
+	if fi.IsDir() {
+		return output.fileError(path, os.ErrNotExist)
+	}
+	header := output.Context.ResponseWriter.Header()
+	if header.Get("ETag") == "" {
+		header.Set("ETag", fmt.Sprintf(`W/"%x-%x"`, fi.Size(), fi.ModTime().UnixNano()))
+	}
+	output.ServeContent(fi.Name(), fi.ModTime(), f)
+	return nil
+}
+
+//以附件的形式下载本地文件，filename为下载时的文件名，默认为path里的文件名
+func (output *ThingoOuput) Download(path string, filename ...string) error {
+	name := filepath.Base(path)
+	if len(filename) > 0 && filename[0] != "" {
+		name = filename[0]
+	}
+	output.Attachment(name)
+	return output.ServeFile(path)
+}
+
+/**
+设置Content-Disposition为附件，filename为下载时的文件名，为空时不带文件名
+非ASCII的文件名按RFC 5987编码到filename*里，同时给不支持的客户端一个ASCII的filename
+*/
+func (output *ThingoOuput) Attachment(filename string) {
+	output.AddHeader("Content-Disposition", contentDisposition("attachment", filename))
+}
+
+//生成Content-Disposition的值
+func contentDisposition(kind, filename string) string {
+	if filename == "" {
+		return kind
+	}
+	ascii := true
+	fallback := make([]byte, 0, len(filename))
+	for _, r := range filename {
+		switch {
+		case r >= 0x80:
+			ascii = false
+			fallback = append(fallback, '_')
+		case r < 0x20 || r == 0x7f || r == '"' || r == '\\':
+			fallback = append(fallback, '_')
+		default:
+			fallback = append(fallback, byte(r))
+		}
+	}
+	ret := kind + `; filename="` + string(fallback) + `"`
+	if !ascii {
+		ret += "; filename*=UTF-8''" + rfc5987Escape(filename)
+	}
+	return ret
+}
+
+//按RFC 5987的attr-char转义，其余的字节都转为%XX
+func rfc5987Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+//打开文件出错时设置状态码并返回对应的错误
+func (output *ThingoOuput) fileError(path string, err error) error {
+	status := http.StatusInternalServerError
+	switch {
+	case os.IsNotExist(err):
+		status = http.StatusNotFound
+	case os.IsPermission(err):
+		status = http.StatusForbidden
+	}
+	output.SetStatus(status)
+	output.AddHeader("Content-Type", "text/plain; charset=utf-8")
+	output.SetBody([]byte(http.StatusText(status)))
+	return &ThingoHTTPError{Status: status, Msg: "serve file " + path, Err: err}
+}
@@ -0,0 +1,172 @@
+package context
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testFileContent = "0123456789abcdef"
+
+//在临时目录里写一个文件，修改时间固定
+func writeTestFile(t *testing.T, name string) (string, time.Time) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(testFileContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	return path, mtime
+}
+
+//请求一次文件，headers为请求的头信息
+func serveTestFile(path string, headers map[string]string) (*httptest.ResponseRecorder, error) {
+	req := httptest.NewRequest("GET", "/file", nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	ctx, rec := newTestContext(req)
+	err := ctx.Output.ServeFile(path)
+	ctx.Output.Send()
+	return rec, err
+}
+
+func TestServeFile(t *testing.T) {
+	path, mtime := writeTestFile(t, "a.txt")
+	rec, err := serveTestFile(path, nil)
+	if err != nil || rec.Code != http.StatusOK || rec.Body.String() != testFileContent {
+		t.Fatalf("got %d %q, %v", rec.Code, rec.Body.String(), err)
+	}
+	etag := rec.Header().Get("ETag")
+	want := map[string]string{
+		"Content-Type":   "text/plain; charset=utf-8",
+		"Content-Length": "16",
+		"Accept-Ranges":  "bytes",
+		"Last-Modified":  mtime.Format(http.TimeFormat),
+	}
+	for k, v := range want {
+		if got := rec.Header().Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+	if !strings.HasPrefix(etag, `W/"10-`) {
+		t.Errorf("ETag = %q", etag)
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+		body    string
+		header  [2]string //要检查的一个响应头
+	}{
+		{"range", map[string]string{"Range": "bytes=0-4"}, http.StatusPartialContent, "01234", [2]string{"Content-Range", "bytes 0-4/16"}},
+		{"suffix range", map[string]string{"Range": "bytes=-3"}, http.StatusPartialContent, "def", [2]string{"Content-Range", "bytes 13-15/16"}},
+		{"open range", map[string]string{"Range": "bytes=10-"}, http.StatusPartialContent, "abcdef", [2]string{"Content-Length", "6"}},
+		{"unsatisfiable", map[string]string{"Range": "bytes=100-200"}, http.StatusRequestedRangeNotSatisfiable, "", [2]string{"Content-Range", "bytes */16"}},
+		{"if-none-match", map[string]string{"If-None-Match": etag}, http.StatusNotModified, "", [2]string{"ETag", etag}},
+		{"if-none-match mismatch", map[string]string{"If-None-Match": `"other"`}, http.StatusOK, testFileContent, [2]string{"ETag", etag}},
+		{"if-modified-since", map[string]string{"If-Modified-Since": mtime.Format(http.TimeFormat)}, http.StatusNotModified, "", [2]string{"Content-Length", ""}},
+		{"modified", map[string]string{"If-Modified-Since": mtime.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK, testFileContent, [2]string{"Content-Length", "16"}},
+		//If-Range不匹配时返回整个文件
+		{"if-range mismatch", map[string]string{"Range": "bytes=0-4", "If-Range": mtime.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK, testFileContent, [2]string{"Content-Range", ""}},
+		{"if-range match", map[string]string{"Range": "bytes=0-4", "If-Range": mtime.Format(http.TimeFormat)}, http.StatusPartialContent, "01234", [2]string{"Content-Range", "bytes 0-4/16"}},
+	}
+	for _, tt := range tests {
+		rec, err := serveTestFile(path, tt.headers)
+		if err != nil || rec.Code != tt.status {
+			t.Errorf("%s: status = %d, %v, want %d", tt.name, rec.Code, err, tt.status)
+			continue
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s: body = %q, want %q", tt.name, rec.Body.String(), tt.body)
+		}
+		if got := rec.Header().Get(tt.header[0]); got != tt.header[1] {
+			t.Errorf("%s: %s = %q, want %q", tt.name, tt.header[0], got, tt.header[1])
+		}
+	}
+}
+
+//多个区间时为multipart/byteranges
+func TestServeFileMultiRange(t *testing.T) {
+	path, _ := writeTestFile(t, "a.txt")
+	rec, _ := serveTestFile(path, map[string]string{"Range": "bytes=0-1,4-5"})
+	body := rec.Body.String()
+	if rec.Code != http.StatusPartialContent || !strings.HasPrefix(rec.Header().Get("Content-Type"), "multipart/byteranges; boundary=") {
+		t.Fatalf("got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	for _, s := range []string{"Content-Range: bytes 0-1/16", "\r\n\r\n01\r\n", "Content-Range: bytes 4-5/16", "\r\n\r\n45\r\n"} {
+		if !strings.Contains(body, s) {
+			t.Errorf("body missing %q: %q", s, body)
+		}
+	}
+}
+
+//调用前设置过的Content-Type、ETag不会被覆盖，暂存的cookie照常输出，暂存的状态码、body不再生效
+func TestServeFileKeepsHeaders(t *testing.T) {
+	path, _ := writeTestFile(t, "a.bin")
+	ctx, rec := newTestContext(httptest.NewRequest("GET", "/file", nil))
+	ctx.Output.AddHeader("Content-Type", "application/x-custom")
+	ctx.Output.AddHeader("ETag", `"mine"`)
+	ctx.Output.AddCookie("sid", "abc")
+	ctx.Output.SetStatus(http.StatusTeapot)
+	ctx.Output.SetBody([]byte("ignored"))
+	if err := ctx.Output.ServeFile(path); err != nil {
+		t.Fatal(err)
+	}
+	ctx.Output.Send()
+	if rec.Code != http.StatusOK || rec.Body.String() != testFileContent {
+		t.Errorf("got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Content-Type") != "application/x-custom" || rec.Header().Get("ETag") != `"mine"` || len(rec.Result().Cookies()) != 1 {
+		t.Errorf("headers = %v", rec.Header())
+	}
+}
+
+func TestServeFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	for _, path := range []string{filepath.Join(dir, "missing.txt"), dir} {
+		rec, err := serveTestFile(path, nil)
+		if ErrorStatus(err) != http.StatusNotFound || rec.Code != http.StatusNotFound || rec.Body.String() != "Not Found" {
+			t.Errorf("%s: got %d %q, %v", path, rec.Code, rec.Body.String(), err)
+		}
+	}
+}
+
+func TestDownload(t *testing.T) {
+	path, _ := writeTestFile(t, "report.csv")
+	tests := []struct {
+		filename []string
+		want     string
+	}{
+		{nil, `attachment; filename="report.csv"`},
+		{[]string{"2026 Q3.csv"}, `attachment; filename="2026 Q3.csv"`},
+		{[]string{`a"b\c.csv`}, `attachment; filename="a_b_c.csv"`},
+		{[]string{"报表.csv"}, `attachment; filename="__.csv"; filename*=UTF-8''%E6%8A%A5%E8%A1%A8.csv`},
+	}
+	for _, tt := range tests {
+		ctx, rec := newTestContext(httptest.NewRequest("GET", "/file", nil))
+		if err := ctx.Output.Download(path, tt.filename...); err != nil {
+			t.Fatal(err)
+		}
+		if got := rec.Header().Get("Content-Disposition"); got != tt.want {
+			t.Errorf("Download(%v): Content-Disposition = %q, want %q", tt.filename, got, tt.want)
+		}
+		if rec.Body.String() != testFileContent {
+			t.Errorf("Download(%v): body = %q", tt.filename, rec.Body.String())
+		}
+	}
+	ctx, rec := newTestContext(httptest.NewRequest("GET", "/file", nil))
+	ctx.Output.Attachment("")
+	if got := rec.Header().Get("Content-Disposition"); got != "attachment" {
+		t.Errorf("Attachment(\"\") = %q", got)
+	}
+}
@@ -3,10 +3,10 @@ package context
 import (
 	"bytes"
 	"compress/gzip"
-	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"reflect"
 	"strings"
 )
@@ -14,9 +14,13 @@ import (
 //请求输入的相关信息结构体
 type ThingoInput struct {
 	Context     *ThingoContext    //上下文的指针
-	Args        map[string]string //所有的参数
+	Args        map[string]string //路由里的参数，包括域名里的，每个参数只有一个值
 	RequestBody []byte
 	Controller  reflect.Type //相关的控制层
+	query       url.Values   //URL里的参数，第一次用到时解析
+	clientInfo  *clientInfo  //客户端的IP、协议、域名，第一次用到时解析
+	formParsed  bool         //表单是否已经解析过了
+	formErr     error        //解析表单时的错误
 }
 
 //新建输入结构体
@@ -34,6 +38,10 @@ func (input *ThingoInput) Reset(ctx *ThingoContext) {
 	input.Args = make(map[string]string)
 	input.RequestBody = []byte{}
 	input.Controller = nil
+	input.query = nil
+	input.clientInfo = nil
+	input.formParsed = false
+	input.formErr = nil
 }
 
 //提取请求时用的协议，如"HTTP/1.1"
@@ -56,18 +64,9 @@ func (input *ThingoInput) Site() string {
 	return input.Scheme() + "://" + input.Domain()
 }
 
-//请求协议，一般为“http”、“https”
+//请求协议，一般为“http”、“https”，经过信任的代理时取代理转发过来的
 func (input *ThingoInput) Scheme() string {
-	if scheme := input.Header("X-Forwarded-Proto"); scheme != "" {
-		return scheme
-	}
-	if input.Context.Request.URL.Scheme != "" {
-		return input.Context.Request.URL.Scheme
-	}
-	if input.Context.Request.TLS == nil {
-		return "http"
-	}
-	return "https"
+	return input.client().scheme
 }
 
 //域名信息
@@ -80,14 +79,10 @@ func (input *ThingoInput) GetCookie(key string) string {
 	return input.Cookie(key)
 }
 
-//域名信息
+//域名信息，不带端口，经过信任的代理时取代理转发过来的
 func (input *ThingoInput) Host() string {
-	if input.Context.Request.Host != "" {
-		hostParts := strings.Split(input.Context.Request.Host, ":")
-		if len(hostParts) > 0 {
-			return hostParts[0]
-		}
-		return input.Context.Request.Host
+	if host := stripPort(input.client().host); host != "" {
+		return host
 	}
 	return "localhost"
 }
@@ -142,28 +137,22 @@ func (input *ThingoInput) IsUpload() bool {
 	return strings.Contains(input.Header("Content-Type"), "multipart/form-data")
 }
 
-//客户端
+/**
+客户端的IP
+直接连过来的一方是信任的代理时，从Forwarded或X-Forwarded-For里最近的一跳往前找，
+第一个不是信任代理的即为客户端，没有这两个头时用X-Real-IP
+客户端的地址无效时（如“unknown”）用连接的地址
+*/
 func (input *ThingoInput) IP() string {
-	ips := input.Proxy()
-	if len(ips) > 0 && ips[0] != "" {
-		rip := strings.Split(ips[0], ":")
-		return rip[0]
-	}
-	ip := strings.Split(input.Context.Request.RemoteAddr, ":")
-	if len(ip) > 0 {
-		if ip[0] != "[" {
-			return ip[0]
-		}
+	if ip := input.client().ip; ip != "" {
+		return ip
 	}
 	return "127.0.0.1"
 }
 
-// Proxy returns proxy client ips slice.
+//X-Forwarded-For里的IP列表，未经校验，离当前服务越近的越靠后
 func (input *ThingoInput) Proxy() []string {
-	if ips := input.Header("X-Forwarded-For"); ips != "" {
-		return strings.Split(ips, ",")
-	}
-	return []string{}
+	return splitHeaderList(input.Context.Request.Header.Values("X-Forwarded-For"))
 }
 
 //返回Referer信息
@@ -181,44 +170,126 @@ func (input *ThingoInput) UserAgent() string {
 	return input.Header("User-Agent")
 }
 
-//参数长度
+/**
+参数分三处存放，互不覆盖：
+	路由里的参数：Args，由路由匹配时设置
+	URL里的参数：QueryValues
+	POST表单里的参数：FormValues，只含body里的
+Param、ParamValues、Params按“路由 > URL > 表单”的优先级查找
+路由参数是单值的，同名的路由参数会遮住URL、表单里的所有值
+*/
+
+//参数个数，三处的参数合并后计算
 func (input *ThingoInput) ParamsLen() int {
-	return len(input.Args)
+	return len(input.Params())
 }
 
-//提取某个参数
+//提取某个参数，按“路由 > URL > 表单”的优先级
 func (input *ThingoInput) Param(key string) string {
-	ret, ok := input.Args[key]
-	if !ok {
-		return ""
+	if vs := input.ParamValues(key); len(vs) > 0 {
+		return vs[0]
 	}
-	return ret
+	return ""
 }
 
-//所有的参数
+/**
+提取某个参数的所有值，取优先级最高的那一处里的
+路由参数只有一个值，命中路由参数时只返回一个元素，不会再合并URL、表单里的同名参数
+*/
+func (input *ThingoInput) ParamValues(key string) []string {
+	if v, ok := input.Args[key]; ok {
+		return []string{v}
+	}
+	if vs, ok := input.QueryValues()[key]; ok {
+		return vs
+	}
+	if vs, ok := input.FormValues()[key]; ok {
+		return vs
+	}
+	return nil
+}
+
+//所有的参数，同名时取优先级高的，多个值的只取第一个
 func (input *ThingoInput) Params() map[string]string {
-	return input.Args
+	ret := make(map[string]string)
+	for _, vals := range []url.Values{input.FormValues(), input.QueryValues()} {
+		for k, vs := range vals {
+			if len(vs) > 0 {
+				ret[k] = vs[0]
+			} else {
+				ret[k] = ""
+			}
+		}
+	}
+	for k, v := range input.Args {
+		ret[k] = v
+	}
+	return ret
 }
 
-//设置某个参数的值
+//设置某个路由参数的值
 func (input *ThingoInput) SetParam(key, val string) {
 	input.Args[key] = val
 }
 
-//清除所有的参数
+//清除所有的路由参数
 func (input *ThingoInput) ResetParams() {
 	input.Args = make(map[string]string)
 }
 
-//提取一个参数值，包括/POST
+//提取一个参数值，同Param
 func (input *ThingoInput) Query(key string) string {
-	if val := input.Param(key); val != "" {
-		return val
+	return input.Param(key)
+}
+
+//URL里的所有参数
+func (input *ThingoInput) QueryValues() url.Values {
+	if input.query == nil {
+		input.query = input.Context.Request.URL.Query()
+	}
+	return input.query
+}
+
+//URL里某个参数的所有值，如“?tag=a&tag=b”
+func (input *ThingoInput) QueryArray(key string) []string {
+	return input.QueryValues()[key]
+}
+
+//URL里“a[x]=1&a[y]=2”这类参数，返回{"x":"1","y":"2"}
+func (input *ThingoInput) QueryMap(key string) map[string]string {
+	return valuesMap(input.QueryValues(), key)
+}
+
+//POST表单里的所有参数，含multipart的，还没有解析时先解析
+func (input *ThingoInput) FormValues() url.Values {
+	req := input.Context.Request
+	if req.PostForm == nil {
+		input.ParseFormOrMulitForm(input.maxMemory())
 	}
-	if input.Context.Request.Form == nil {
-		input.Context.Request.ParseForm()
+	return req.PostForm
+}
+
+//POST表单里某个参数的所有值
+func (input *ThingoInput) FormArray(key string) []string {
+	return input.FormValues()[key]
+}
+
+//POST表单里“a[x]=1&a[y]=2”这类参数，返回{"x":"1","y":"2"}
+func (input *ThingoInput) FormMap(key string) map[string]string {
+	return valuesMap(input.FormValues(), key)
+}
+
+//提取“key[x]”这类参数，多个值的只取第一个
+func valuesMap(vals url.Values, key string) map[string]string {
+	ret := make(map[string]string)
+	prefix := key + "["
+	for k, vs := range vals {
+		if !strings.HasPrefix(k, prefix) || !strings.HasSuffix(k, "]") || len(vs) == 0 {
+			continue
+		}
+		ret[k[len(prefix):len(k)-1]] = vs[0]
 	}
-	return input.Context.Request.Form.Get(key)
+	return ret
 }
 
 //提取头信息里的信息
@@ -260,14 +331,47 @@ func (input *ThingoInput) CopyBody(MaxMemory int64) []byte {
 	return requestbody
 }
 
-//解析请求的表单
+/**
+解析请求的表单，只解析一次，再次调用时返回第一次解析的错误
+表单的body最多maxMemory个字节，超过时返回413，格式有误时返回400
+*/
 func (input *ThingoInput) ParseFormOrMulitForm(maxMemory int64) error {
-	if strings.Contains(input.Header("Content-Type"), "multipart/form-data") {
-		if err := input.Context.Request.ParseMultipartForm(maxMemory); err != nil {
-			return errors.New("Error parsing request body:" + err.Error())
-		}
-	} else if err := input.Context.Request.ParseForm(); err != nil {
-		return errors.New("Error parsing request body:" + err.Error())
+	if input.formParsed {
+		return input.formErr
 	}
-	return nil
+	input.formParsed = true
+	req := input.Context.Request
+	ct := input.Header("Content-Type")
+	var body *countingBody
+	if req.Body != nil && (strings.Contains(ct, "multipart/form-data") || strings.Contains(ct, "application/x-www-form-urlencoded")) {
+		body = &countingBody{ReadCloser: req.Body}
+		req.Body = http.MaxBytesReader(input.Context.ResponseWriter, body, maxMemory)
+	}
+	var err error
+	if strings.Contains(ct, "multipart/form-data") {
+		err = req.ParseMultipartForm(maxMemory)
+	} else {
+		err = req.ParseForm()
+	}
+	if err == nil {
+		return nil
+	}
+	if body != nil && body.n > maxMemory {
+		input.formErr = NewHTTPError(http.StatusRequestEntityTooLarge, "request body exceeds %d bytes", maxMemory)
+	} else {
+		input.formErr = &ThingoHTTPError{Status: http.StatusBadRequest, Msg: "Error parsing request body", Err: err}
+	}
+	return input.formErr
+}
+
+//记录从body里读了多少字节，用来区分body超限和格式有误
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
 }
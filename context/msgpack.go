@@ -0,0 +1,230 @@
+package context
+
+import (
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+//输出MessagePack，结构体编码成map，字段名依次取msgpack、json标签，都没有时用字段名
+type msgPackRenderer struct{}
+
+func (msgPackRenderer) ContentType() string {
+	return "application/msgpack"
+}
+
+func (msgPackRenderer) Render(w io.Writer, data interface{}) error {
+	e := &msgPackEncoder{}
+	if err := e.encode(reflect.ValueOf(data)); err != nil {
+		return err
+	}
+	_, err := w.Write(e.buf)
+	return err
+}
+
+//把数据编码成MessagePack格式
+type msgPackEncoder struct {
+	buf []byte
+}
+
+func (e *msgPackEncoder) writeByte(b byte) {
+	e.buf = append(e.buf, b)
+}
+
+//写入类型标识及大端序的长度或数值
+func (e *msgPackEncoder) writeUint(code byte, n uint64, size int) {
+	e.buf = append(e.buf, code)
+	switch size {
+	case 1:
+		e.buf = append(e.buf, byte(n))
+	case 2:
+		e.buf = append(e.buf, 0, 0)
+		binary.BigEndian.PutUint16(e.buf[len(e.buf)-2:], uint16(n))
+	case 4:
+		e.buf = append(e.buf, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(e.buf[len(e.buf)-4:], uint32(n))
+	case 8:
+		e.buf = append(e.buf, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(e.buf[len(e.buf)-8:], n)
+	}
+}
+
+func (e *msgPackEncoder) encode(v reflect.Value) error {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			break
+		}
+		if v.Kind() == reflect.Ptr && v.Type().Implements(textMarshalerType) && v.Elem().Type() != reflect.TypeOf(time.Time{}) {
+			break
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() || ((v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil()) {
+		e.writeByte(0xc0)
+		return nil
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		e.encodeTime(t)
+		return nil
+	}
+	if v.Type().Implements(textMarshalerType) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		e.encodeString(string(b))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.writeByte(0xc3)
+		} else {
+			e.writeByte(0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeUint(v.Uint())
+	case reflect.Float32:
+		e.writeUint(0xca, uint64(math.Float32bits(float32(v.Float()))), 4)
+	case reflect.Float64:
+		e.writeUint(0xcb, math.Float64bits(v.Float()), 8)
+	case reflect.String:
+		e.encodeString(v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			e.encodeBytes(b)
+			return nil
+		}
+		e.encodeLen(v.Len(), 0x90, 0xdc, 0xdd)
+		for i := 0; i < v.Len(); i++ {
+			if err := e.encode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		e.encodeLen(len(keys), 0x80, 0xde, 0xdf)
+		for _, k := range keys {
+			if err := e.encode(k); err != nil {
+				return err
+			}
+			if err := e.encode(v.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		fields := structFields(v, "msgpack", func(s string) string { return s })
+		e.encodeLen(len(fields), 0x80, 0xde, 0xdf)
+		for _, f := range fields {
+			e.encodeString(f.key)
+			if err := e.encode(f.val); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+//数组、map的长度，fix为长度小于16时的类型标识
+func (e *msgPackEncoder) encodeLen(n int, fix, code16, code32 byte) {
+	switch {
+	case n < 16:
+		e.writeByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		e.writeUint(code16, uint64(n), 2)
+	default:
+		e.writeUint(code32, uint64(n), 4)
+	}
+}
+
+func (e *msgPackEncoder) encodeInt(n int64) {
+	switch {
+	case n >= 0:
+		e.encodeUint(uint64(n))
+	case n >= -32:
+		e.writeByte(byte(n))
+	case n >= math.MinInt8:
+		e.writeUint(0xd0, uint64(n), 1)
+	case n >= math.MinInt16:
+		e.writeUint(0xd1, uint64(n), 2)
+	case n >= math.MinInt32:
+		e.writeUint(0xd2, uint64(n), 4)
+	default:
+		e.writeUint(0xd3, uint64(n), 8)
+	}
+}
+
+func (e *msgPackEncoder) encodeUint(n uint64) {
+	switch {
+	case n <= 0x7f:
+		e.writeByte(byte(n))
+	case n <= math.MaxUint8:
+		e.writeUint(0xcc, n, 1)
+	case n <= math.MaxUint16:
+		e.writeUint(0xcd, n, 2)
+	case n <= math.MaxUint32:
+		e.writeUint(0xce, n, 4)
+	default:
+		e.writeUint(0xcf, n, 8)
+	}
+}
+
+func (e *msgPackEncoder) encodeString(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		e.writeByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		e.writeUint(0xd9, uint64(n), 1)
+	case n <= math.MaxUint16:
+		e.writeUint(0xda, uint64(n), 2)
+	default:
+		e.writeUint(0xdb, uint64(n), 4)
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *msgPackEncoder) encodeBytes(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		e.writeUint(0xc4, uint64(n), 1)
+	case n <= math.MaxUint16:
+		e.writeUint(0xc5, uint64(n), 2)
+	default:
+		e.writeUint(0xc6, uint64(n), 4)
+	}
+	e.buf = append(e.buf, b...)
+}
+
+//时间用扩展类型-1（timestamp）编码
+func (e *msgPackEncoder) encodeTime(t time.Time) {
+	sec, nsec := t.Unix(), int64(t.Nanosecond())
+	switch {
+	case sec>>34 == 0 && nsec == 0:
+		e.writeByte(0xd6)
+		e.writeUint(0xff, uint64(sec), 4)
+	case sec>>34 == 0:
+		e.writeByte(0xd7)
+		e.writeUint(0xff, uint64(nsec)<<34|uint64(sec), 8)
+	default:
+		e.writeUint(0xc7, 12, 1)
+		e.writeUint(0xff, uint64(nsec), 4)
+		e.buf = append(e.buf, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(e.buf[len(e.buf)-8:], uint64(sec))
+	}
+}
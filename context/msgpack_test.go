@@ -0,0 +1,185 @@
+package context
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+//按MessagePack规范里的字节序列检查编码结果
+func msgPackHex(t *testing.T, data interface{}) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := (msgPackRenderer{}).Render(&buf, data); err != nil {
+		t.Fatalf("Render(%#v) failed: %v", data, err)
+	}
+	return hex.EncodeToString(buf.Bytes())
+}
+
+type msgPackBase struct {
+	ID int `json:"id"`
+}
+
+type msgPackItem struct {
+	msgPackBase
+	Name    string `msgpack:"n" json:"name"`
+	Note    string `json:"note,omitempty"`
+	Skip    string `json:"-"`
+	Enabled bool
+	hidden  int
+}
+
+func TestMsgPackScalars(t *testing.T) {
+	var nilPtr *int
+	tests := []struct {
+		data interface{}
+		want string
+	}{
+		{nil, "c0"},
+		{nilPtr, "c0"},
+		{true, "c3"},
+		{false, "c2"},
+		//positive fixint、uint8/16/32/64
+		{0, "00"},
+		{127, "7f"},
+		{128, "cc80"},
+		{255, "ccff"},
+		{256, "cd0100"},
+		{65535, "cdffff"},
+		{65536, "ce00010000"},
+		{uint32(math.MaxUint32), "ceffffffff"},
+		{int64(math.MaxUint32 + 1), "cf0000000100000000"},
+		{uint64(math.MaxUint64), "cfffffffffffffffff"},
+		//negative fixint、int8/16/32/64
+		{-1, "ff"},
+		{-32, "e0"},
+		{-33, "d0df"},
+		{int8(math.MinInt8), "d080"},
+		{-129, "d1ff7f"},
+		{int16(math.MinInt16), "d18000"},
+		{-32769, "d2ffff7fff"},
+		{int32(math.MinInt32), "d280000000"},
+		{int64(math.MinInt32 - 1), "d3ffffffff7fffffff"},
+		{int64(math.MinInt64), "d38000000000000000"},
+		//float32、float64
+		{float32(1.5), "ca3fc00000"},
+		{1.5, "cb3ff8000000000000"},
+		{math.Inf(-1), "cbfff0000000000000"},
+		//fixstr、str8，长度按字节计
+		{"", "a0"},
+		{"a", "a161"},
+		{"中", "a3e4b8ad"},
+		{strings.Repeat("x", 31), "bf" + strings.Repeat("78", 31)},
+		{strings.Repeat("x", 32), "d920" + strings.Repeat("78", 32)},
+		//[]byte及[N]byte编码成bin
+		{[]byte{}, "c400"},
+		{[]byte{1, 2, 3}, "c403010203"},
+		{[2]byte{0xde, 0xad}, "c402dead"},
+	}
+	for _, tt := range tests {
+		if got := msgPackHex(t, tt.data); got != tt.want {
+			t.Errorf("encode %#v = %s, want %s", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestMsgPackLengths(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   interface{}
+		prefix string
+		size   int
+	}{
+		{"str16", strings.Repeat("x", 256), "da0100", 3 + 256},
+		{"str32", strings.Repeat("x", 65536), "db00010000", 5 + 65536},
+		{"bin16", make([]byte, 256), "c50100", 3 + 256},
+		{"bin32", make([]byte, 65536), "c600010000", 5 + 65536},
+		{"fixarray", make([]int, 15), "9f", 1 + 15},
+		{"array16", make([]int, 16), "dc0010", 3 + 16},
+		{"array32", make([]bool, 65536), "dd00010000", 5 + 65536},
+	}
+	for _, tt := range tests {
+		got := msgPackHex(t, tt.data)
+		if !strings.HasPrefix(got, tt.prefix) || len(got) != tt.size*2 {
+			t.Errorf("%s: got prefix %s len %d, want prefix %s len %d", tt.name, got[:len(tt.prefix)], len(got)/2, tt.prefix, tt.size)
+		}
+	}
+	m := make(map[int]bool, 16)
+	for i := 0; i < 16; i++ {
+		m[i] = true
+	}
+	if got := msgPackHex(t, m); !strings.HasPrefix(got, "de0010") || len(got) != (3+32)*2 {
+		t.Errorf("map16: got %s", got)
+	}
+}
+
+func TestMsgPackContainers(t *testing.T) {
+	tests := []struct {
+		data interface{}
+		want string
+	}{
+		{[]int{1, -1}, "9201ff"},
+		{[]interface{}{nil, "a", []string{}}, "93c0a16190"},
+		{map[string]int{}, "80"},
+		//map的键排序后输出
+		{map[string]int{"b": 2, "a": 1, "c": 3}, "83a16101a16202a16303"},
+		{map[string]interface{}{"x": map[string]bool{"y": true}}, "81a17881a179c3"},
+	}
+	for _, tt := range tests {
+		if got := msgPackHex(t, tt.data); got != tt.want {
+			t.Errorf("encode %#v = %s, want %s", tt.data, got, tt.want)
+		}
+	}
+}
+
+//结构体编码成map，展开嵌入的结构体，跳过未导出、"-"及omitempty的空字段
+func TestMsgPackStruct(t *testing.T) {
+	item := msgPackItem{msgPackBase: msgPackBase{ID: 7}, Name: "go", Skip: "x", hidden: 1}
+	want := "83" + "a2696407" + "a16ea2676f" + "a7456e61626c6564c2"
+	if got := msgPackHex(t, item); got != want {
+		t.Errorf("encode struct = %s, want %s", got, want)
+	}
+	if got := msgPackHex(t, &item); got != want {
+		t.Errorf("encode struct pointer = %s, want %s", got, want)
+	}
+	item.Note = "n"
+	want = "84" + "a2696407" + "a16ea2676f" + "a46e6f7465a16e" + "a7456e61626c6564c2"
+	if got := msgPackHex(t, item); got != want {
+		t.Errorf("encode struct with note = %s, want %s", got, want)
+	}
+}
+
+//时间用timestamp扩展类型，按秒数和纳秒选择32、64、96位的格式
+func TestMsgPackTime(t *testing.T) {
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{time.Unix(0, 0), "d6ff00000000"},
+		{time.Unix(1, 0), "d6ff00000001"},
+		{time.Unix(1, 1), "d7ff0000000400000001"},
+		{time.Unix(1<<34, 0), "c70cff000000000000000400000000"},
+		{time.Unix(-1, 0), "c70cff00000000ffffffffffffffff"},
+	}
+	for _, tt := range tests {
+		if got := msgPackHex(t, tt.t); got != tt.want {
+			t.Errorf("encode %v = %s, want %s", tt.t, got, tt.want)
+		}
+		if got := msgPackHex(t, &tt.t); got != tt.want {
+			t.Errorf("encode &%v = %s, want %s", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestMsgPackUnsupported(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (msgPackRenderer{}).Render(&buf, make(chan int)); err == nil {
+		t.Error("encode chan should fail")
+	}
+	if err := (msgPackRenderer{}).Render(&buf, []interface{}{func() {}}); err == nil {
+		t.Error("encode func in slice should fail")
+	}
+}
@@ -0,0 +1,126 @@
+package context
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+//Accept头里的单个类型
+type acceptSpec struct {
+	typ     string  //主类型，如“text”，可以为“*”
+	subtype string  //子类型，如“html”，可以为“*”
+	q       float64 //权重，0表示不接受
+}
+
+//解析Accept头，格式有误的项直接忽略
+func parseAccept(header string) []acceptSpec {
+	var specs []acceptSpec
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		pos := strings.Index(mediaType, "/")
+		if pos < 0 {
+			if mediaType != "*" {
+				continue
+			}
+			mediaType, pos = "*/*", 1
+		}
+		spec := acceptSpec{typ: mediaType[:pos], subtype: mediaType[pos+1:], q: 1}
+		if qs, ok := params["q"]; ok {
+			q, err := strconv.ParseFloat(qs, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+			spec.q = q
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+//某个类型在Accept里的权重，取最具体的那一项，没有匹配的项时返回-1
+func acceptQuality(specs []acceptSpec, offer string) float64 {
+	typ, subtype := offer, ""
+	if pos := strings.Index(offer, "/"); pos >= 0 {
+		typ, subtype = offer[:pos], offer[pos+1:]
+	}
+	q, level := -1.0, -1
+	for _, s := range specs {
+		l := -1
+		switch {
+		case s.typ == typ && s.subtype == subtype:
+			l = 2
+		case s.typ == typ && s.subtype == "*":
+			l = 1
+		case s.typ == "*" && s.subtype == "*":
+			l = 0
+		}
+		if l > level {
+			q, level = s.q, l
+		}
+	}
+	return q
+}
+
+/**
+按Accept头从offers里选出客户端最想要的类型，如：
+	input.Accepts("application/json", "text/html")
+
+权重相同时按offers的顺序，没有Accept头时返回第一个，都不接受时返回空字符串
+offers里的参数（如“; charset=utf-8”）不参与比较
+*/
+func (input *ThingoInput) Accepts(offers ...string) string {
+	if accepted := input.acceptedOffers(offers); len(accepted) > 0 {
+		return accepted[0]
+	}
+	return ""
+}
+
+//offers里客户端可以接受的类型，按权重从高到低排好，权重相同时按offers的顺序，没有Accept头时原样返回
+func (input *ThingoInput) acceptedOffers(offers []string) []string {
+	header := strings.Join(input.Context.Request.Header.Values("Accept"), ",")
+	if strings.TrimSpace(header) == "" {
+		return offers
+	}
+	specs := parseAccept(header)
+	var accepted []string
+	var qs []float64
+	for _, offer := range offers {
+		q := acceptQuality(specs, offerMediaType(offer))
+		if q <= 0 {
+			continue
+		}
+		pos := len(accepted)
+		for pos > 0 && qs[pos-1] < q {
+			pos--
+		}
+		accepted = append(accepted, "")
+		copy(accepted[pos+1:], accepted[pos:])
+		accepted[pos] = offer
+		qs = append(qs, 0)
+		copy(qs[pos+1:], qs[pos:])
+		qs[pos] = q
+	}
+	return accepted
+}
+
+//去掉类型里的参数并转为小写，如“Application/JSON; charset=utf-8”返回“application/json”
+func offerMediaType(offer string) string {
+	mediaType := strings.ToLower(strings.TrimSpace(offer))
+	if pos := strings.Index(mediaType, ";"); pos >= 0 {
+		mediaType = strings.TrimSpace(mediaType[:pos])
+	}
+	return mediaType
+}
+
+//客户端是否接受某个类型
+func (input *ThingoInput) AcceptsType(mediaType string) bool {
+	return input.Accepts(mediaType) != ""
+}
@@ -14,11 +14,13 @@ import (
 
 //输出结构体定义
 type ThingoOuput struct {
-	Context *ThingoContext //本次请求相关的上下文
-	Status  int            //手动设置的响应的http code码
-	Body    []byte         //暂存要发送的响应body的信息
-	Cookies []string       //暂存要发送响应的cookie信息
-	Started bool           //是否开始发送请求了
+	Context   *ThingoContext //本次请求相关的上下文
+	Status    int            //手动设置的响应的http code码
+	Body      []byte         //暂存要发送的响应body的信息
+	Cookies   []string       //暂存要发送响应的cookie信息
+	Started   bool           //是否开始发送请求了
+	Produces  []string       //路由上配置的可输出的类型，给Render协商用
+	Streaming bool           //是否为流式输出，为true时头信息已发出，写入的数据直接发给客户端
 }
 
 //获取一个输出实例
@@ -34,14 +36,21 @@ func (output *ThingoOuput) Reset(ctx *ThingoContext) {
 	output.Body = []byte{}
 	output.Cookies = []string{}
 	output.Started = false
+	output.Produces = nil
+	output.Streaming = false
 
-	//默认添加的几个公共头信息
-	output.AddHeader("Referrer-Policy", "origin-when-cross-origin") //默认的referrer策略
-	output.AddHeader("X-Powered-By", "ThingoGo")
-	output.AddHeader("Server", "ThingoGo")
-	output.AddHeader("Github", "https://github.com/liuyongshuai/Thingogo")
-	output.AddHeader("Cache-Control", "no-cache, must-revalidate")
-	output.AddHeader("Expires", "0")
+	//默认添加的公共头信息及缓存策略，都由配置决定
+	cfg := output.Context.Config
+	if cfg == nil {
+		return
+	}
+	header := output.Context.ResponseWriter.Header()
+	for k, vs := range cfg.Headers {
+		header[k] = append([]string(nil), vs...)
+	}
+	if cfg.CachePolicy != nil {
+		output.SetCachePolicy(*cfg.CachePolicy)
+	}
 }
 
 //设置要输出的header信息
@@ -152,6 +161,30 @@ func (output *ThingoOuput) RenderJson(data interface{}) error {
 	return nil
 }
 
+/**
+按错误输出json，状态码取自err的StatusCode()，没有时为500
+	{"code":400,"msg":"...","errors":[{"field":"Id","rule":"required","msg":"is required"}]}
+
+errors只有绑定、校验失败时才有
+*/
+func (output *ThingoOuput) RenderError(err error) error {
+	status := ErrorStatus(err)
+	ret := map[string]interface{}{
+		"code": status,
+		"msg":  err.Error(),
+	}
+	var bes BindErrors
+	if errors.As(err, &bes) {
+		fields := make([]map[string]string, 0, len(bes))
+		for _, e := range bes {
+			fields = append(fields, map[string]string{"field": e.Field, "rule": e.Rule, "msg": e.Msg})
+		}
+		ret["errors"] = fields
+	}
+	output.SetStatus(status)
+	return output.RenderJson(ret)
+}
+
 //响应jsonp数据，要求传个callback参数
 func (output *ThingoOuput) RenderJsonp(data interface{}, callback ...string) error {
 	var content []byte
@@ -182,34 +215,118 @@ func (output *ThingoOuput) RenderJsonp(data interface{}, callback ...string) err
 	return nil
 }
 
+//返回一个写入到Output里的http.ResponseWriter，给标准库的http.Handler用
+func (output *ThingoOuput) ResponseWriter() http.ResponseWriter {
+	return &outputResponseWriter{output: output}
+}
+
+//把写入的内容暂存到Output里，最后由Send统一输出
+type outputResponseWriter struct {
+	output *ThingoOuput
+}
+
+func (w *outputResponseWriter) Header() http.Header {
+	return w.output.Context.ResponseWriter.Header()
+}
+
+func (w *outputResponseWriter) Write(p []byte) (int, error) {
+	if w.output.Streaming {
+		return w.output.Write(p)
+	}
+	w.output.Body = append(w.output.Body, p...)
+	return len(p), nil
+}
+
+func (w *outputResponseWriter) WriteHeader(status int) {
+	if !w.output.Streaming {
+		w.output.SetStatus(status)
+	}
+}
+
+//调用Flush后转为流式输出
+func (w *outputResponseWriter) Flush() {
+	w.output.Flush()
+}
+
 //设置响应的状态值
 func (output *ThingoOuput) SetStatus(status int) {
 	output.Status = status
 }
 
-//输出所有的内容，包括header、body、cookie等
-func (output *ThingoOuput) Send() {
-	//输出cookie信息
-	if len(output.Cookies) > 0 {
-		for _, c := range output.Cookies {
-			output.Context.ResponseWriter.Header().Add("Set-Cookie", c)
-		}
-	}
-	if output.Started == true {
-		return
+//把暂存的cookie放到头信息里
+func (output *ThingoOuput) addCookieHeaders() {
+	for _, c := range output.Cookies {
+		output.Context.ResponseWriter.Header().Add("Set-Cookie", c)
 	}
+}
 
+//写出cookie及状态码，状态码没有设置时由第一次写body时默认为200
+func (output *ThingoOuput) writeHeader() {
+	output.addCookieHeaders()
 	if output.Status > 0 {
 		output.Context.ResponseWriter.WriteHeader(output.Status)
 		output.Status = 0
 	}
+}
+
+/**
+输出所有的内容，包括header、body、cookie等，已经开始输出时不再处理
+输出前按配置给body生成ETag，客户端的缓存还有效时改为输出304
+*/
+func (output *ThingoOuput) Send() {
+	if output.Started == true {
+		return
+	}
+	output.autoETag()
+	output.NotModified()
+	output.writeHeader()
 
 	//输出body
 	if len(output.Body) > 0 {
-		buf := new(bytes.Buffer)
-		buf.Write(output.Body)
-		io.Copy(output.Context.ResponseWriter, buf)
+		output.Context.ResponseWriter.Write(output.Body)
 	}
 
 	output.Started = true
+	output.Context.Writer.committed = true
+}
+
+/**
+转为流式输出：立即发出头信息、cookie、状态码及已经暂存的body，之后写入的数据直接发给客户端
+没有设置Content-Length时按chunked发送，转为流式后就不能再修改头信息及状态码了
+*/
+func (output *ThingoOuput) Stream() {
+	if output.Streaming {
+		return
+	}
+	output.Streaming = true
+	if output.Started {
+		return
+	}
+	output.Started = true
+	output.writeHeader()
+	if len(output.Body) > 0 {
+		output.Context.ResponseWriter.Write(output.Body)
+	}
+	output.Body = nil
+	output.Context.Writer.committed = true
+}
+
+//实现io.Writer，写入的数据直接发给客户端，还不是流式输出时先转为流式
+func (output *ThingoOuput) Write(p []byte) (int, error) {
+	output.Stream()
+	return output.Context.ResponseWriter.Write(p)
+}
+
+//实现io.ReaderFrom，把r里的数据全部发给客户端
+func (output *ThingoOuput) ReadFrom(r io.Reader) (int64, error) {
+	output.Stream()
+	return io.Copy(output.Context.ResponseWriter, r)
+}
+
+//把已经写入的数据立即发给客户端，还不是流式输出时先转为流式
+func (output *ThingoOuput) Flush() {
+	output.Stream()
+	if f, ok := output.Context.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
 }
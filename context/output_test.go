@@ -0,0 +1,124 @@
+package context
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//转为流式时先发出暂存的头信息、状态码、cookie及body，之后写入的直接发给客户端
+func TestOutputStream(t *testing.T) {
+	ctx, rec := newTestContext(httptest.NewRequest("GET", "/", nil))
+	ctx.Output.SetStatus(http.StatusAccepted)
+	ctx.Output.AddHeader("Content-Type", "text/plain")
+	ctx.Output.AddCookie("sid", "abc")
+	ctx.Output.SetBody([]byte("head,"))
+	if _, err := ctx.Output.Write([]byte("a,")); err != nil {
+		t.Fatal(err)
+	}
+	if !ctx.Output.Streaming || !ctx.Output.Started || !ctx.Writer.Written() {
+		t.Fatalf("Streaming %v, Started %v, Written %v", ctx.Output.Streaming, ctx.Output.Started, ctx.Writer.Written())
+	}
+	if n, err := ctx.Output.ReadFrom(strings.NewReader("b,")); n != 2 || err != nil {
+		t.Errorf("ReadFrom = %d, %v", n, err)
+	}
+	ctx.Output.Flush()
+	if !rec.Flushed {
+		t.Error("not flushed")
+	}
+	//开始流式输出后再设置的状态码、body都不再生效
+	ctx.Output.SetStatus(http.StatusTeapot)
+	ctx.Output.SetBody([]byte("ignored"))
+	ctx.Output.Send()
+	res := rec.Result()
+	if res.StatusCode != http.StatusAccepted || rec.Body.String() != "head,a,b," {
+		t.Errorf("got %d %q", res.StatusCode, rec.Body.String())
+	}
+	if res.Header.Get("Content-Type") != "text/plain" || len(res.Cookies()) != 1 {
+		t.Errorf("headers = %v", res.Header)
+	}
+	if ctx.Writer.Status() != http.StatusAccepted || ctx.Writer.Size() != int64(len("head,a,b,")) {
+		t.Errorf("Writer: status %d, size %d", ctx.Writer.Status(), ctx.Writer.Size())
+	}
+}
+
+//标准库的http.Handler写入的内容先暂存，调用Flush后转为流式
+func TestOutputResponseWriter(t *testing.T) {
+	ctx, rec := newTestContext(httptest.NewRequest("GET", "/", nil))
+	w := ctx.Output.ResponseWriter()
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte("buffered,"))
+	if ctx.Output.Streaming || rec.Body.Len() != 0 {
+		t.Fatalf("written before Flush: %q", rec.Body.String())
+	}
+	w.(http.Flusher).Flush()
+	w.WriteHeader(http.StatusTeapot)
+	w.Write([]byte("streamed"))
+	ctx.Output.Send()
+	if rec.Code != http.StatusCreated || rec.Body.String() != "buffered,streamed" {
+		t.Errorf("got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+//没有Content-Length时按chunked发送，Flush后客户端立即能读到
+func TestOutputStreamChunked(t *testing.T) {
+	next := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := NewThingoContext()
+		ctx.Reset(&w, r)
+		ctx.Output.AddHeader("Content-Type", "text/plain")
+		ctx.Output.Write([]byte("first\n"))
+		ctx.Output.Flush()
+		select {
+		case <-next:
+		case <-time.After(5 * time.Second):
+		}
+		ctx.Output.Write([]byte("second\n"))
+		ctx.Output.Send()
+	}))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if len(resp.TransferEncoding) != 1 || resp.TransferEncoding[0] != "chunked" {
+		t.Errorf("TransferEncoding = %v", resp.TransferEncoding)
+	}
+	br := bufio.NewReader(resp.Body)
+	line, err := br.ReadString('\n')
+	if err != nil || line != "first\n" {
+		t.Fatalf("first chunk = %q, %v", line, err)
+	}
+	close(next)
+	rest, err := ioutil.ReadAll(br)
+	if err != nil || string(rest) != "second\n" {
+		t.Errorf("rest = %q, %v", rest, err)
+	}
+}
+
+//设置了Content-Length时不用chunked
+func TestOutputStreamContentLength(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := NewThingoContext()
+		ctx.Reset(&w, r)
+		ctx.Output.AddHeader("Content-Length", "10")
+		ctx.Output.Write([]byte("01234"))
+		ctx.Output.Flush()
+		ctx.Output.Write([]byte("56789"))
+	}))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.ContentLength != 10 || len(resp.TransferEncoding) != 0 || string(body) != "0123456789" {
+		t.Errorf("ContentLength %d, TransferEncoding %v, body %q", resp.ContentLength, resp.TransferEncoding, body)
+	}
+}
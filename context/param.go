@@ -0,0 +1,223 @@
+package context
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//参数不存在
+var ErrParamMissing = errors.New("missing parameter")
+
+//参数不存在或转换类型失败
+type ParamError struct {
+	Key   string //参数名
+	Value string //原始的值
+	Type  string //要转换成的类型
+	Err   error  //具体的错误，参数不存在时为ErrParamMissing
+}
+
+func (e *ParamError) Error() string {
+	if e.Err == ErrParamMissing {
+		return "missing parameter " + strconv.Quote(e.Key)
+	}
+	return "invalid " + e.Type + " parameter " + strconv.Quote(e.Key) + ": " + strconv.Quote(e.Value)
+}
+
+//对应的HTTP状态码
+func (e *ParamError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+/**
+以下按类型提取参数，查找的优先级同Param
+参数不存在或转换失败时返回*ParamError，状态码为400，可直接给Output.RenderError用
+对应的OrDefault方法在出错时返回默认值
+*/
+
+//提取参数的原始值，不存在时返回错误
+func (input *ThingoInput) paramString(key, typ string) (string, error) {
+	vs := input.ParamValues(key)
+	if len(vs) == 0 || vs[0] == "" {
+		return "", &ParamError{Key: key, Type: typ, Err: ErrParamMissing}
+	}
+	return vs[0], nil
+}
+
+//提取int类型的参数
+func (input *ThingoInput) Int(key string) (int, error) {
+	v, err := input.parseInt(key, strconv.IntSize)
+	return int(v), err
+}
+
+//提取int类型的参数，不存在或转换失败时返回def
+func (input *ThingoInput) IntOrDefault(key string, def int) int {
+	if v, err := input.Int(key); err == nil {
+		return v
+	}
+	return def
+}
+
+//提取int64类型的参数
+func (input *ThingoInput) Int64(key string) (int64, error) {
+	return input.parseInt(key, 64)
+}
+
+//提取int64类型的参数，不存在或转换失败时返回def
+func (input *ThingoInput) Int64OrDefault(key string, def int64) int64 {
+	if v, err := input.Int64(key); err == nil {
+		return v
+	}
+	return def
+}
+
+//按位数转换整数参数
+func (input *ThingoInput) parseInt(key string, bits int) (int64, error) {
+	s, err := input.paramString(key, "int")
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseInt(strings.TrimSpace(s), 10, bits)
+	if err != nil {
+		return 0, &ParamError{Key: key, Value: s, Type: "int", Err: err}
+	}
+	return v, nil
+}
+
+//提取uint类型的参数
+func (input *ThingoInput) Uint(key string) (uint, error) {
+	s, err := input.paramString(key, "uint")
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(s), 10, strconv.IntSize)
+	if err != nil {
+		return 0, &ParamError{Key: key, Value: s, Type: "uint", Err: err}
+	}
+	return uint(v), nil
+}
+
+//提取uint类型的参数，不存在或转换失败时返回def
+func (input *ThingoInput) UintOrDefault(key string, def uint) uint {
+	if v, err := input.Uint(key); err == nil {
+		return v
+	}
+	return def
+}
+
+//提取float64类型的参数
+func (input *ThingoInput) Float(key string) (float64, error) {
+	s, err := input.paramString(key, "float")
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, &ParamError{Key: key, Value: s, Type: "float", Err: err}
+	}
+	return v, nil
+}
+
+//提取float64类型的参数，不存在或转换失败时返回def
+func (input *ThingoInput) FloatOrDefault(key string, def float64) float64 {
+	if v, err := input.Float(key); err == nil {
+		return v
+	}
+	return def
+}
+
+//提取bool类型的参数，支持1/0、t/f、true/false、on/off、yes/no
+func (input *ThingoInput) Bool(key string) (bool, error) {
+	s, err := input.paramString(key, "bool")
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "t", "true", "on", "yes", "y":
+		return true, nil
+	case "0", "f", "false", "off", "no", "n":
+		return false, nil
+	}
+	return false, &ParamError{Key: key, Value: s, Type: "bool", Err: strconv.ErrSyntax}
+}
+
+//提取bool类型的参数，不存在或转换失败时返回def
+func (input *ThingoInput) BoolOrDefault(key string, def bool) bool {
+	if v, err := input.Bool(key); err == nil {
+		return v
+	}
+	return def
+}
+
+//按layout提取时间类型的参数，用本地时区
+func (input *ThingoInput) Time(key, layout string) (time.Time, error) {
+	s, err := input.paramString(key, "time")
+	if err != nil {
+		return time.Time{}, err
+	}
+	v, err := time.ParseInLocation(layout, strings.TrimSpace(s), time.Local)
+	if err != nil {
+		return time.Time{}, &ParamError{Key: key, Value: s, Type: "time", Err: err}
+	}
+	return v, nil
+}
+
+//按layout提取时间类型的参数，不存在或转换失败时返回def
+func (input *ThingoInput) TimeOrDefault(key, layout string, def time.Time) time.Time {
+	if v, err := input.Time(key, layout); err == nil {
+		return v
+	}
+	return def
+}
+
+//提取时长类型的参数，如“1h30m”
+func (input *ThingoInput) Duration(key string) (time.Duration, error) {
+	s, err := input.paramString(key, "duration")
+	if err != nil {
+		return 0, err
+	}
+	v, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return 0, &ParamError{Key: key, Value: s, Type: "duration", Err: err}
+	}
+	return v, nil
+}
+
+//提取时长类型的参数，不存在或转换失败时返回def
+func (input *ThingoInput) DurationOrDefault(key string, def time.Duration) time.Duration {
+	if v, err := input.Duration(key); err == nil {
+		return v
+	}
+	return def
+}
+
+//按sep拆分参数，去掉空白及空的部分，参数有多个值时会全部拆分，如“?id=1,2&id=3”返回[1 2 3]
+func (input *ThingoInput) Strings(key, sep string) ([]string, error) {
+	vs := input.ParamValues(key)
+	var ret []string
+	for _, v := range vs {
+		for _, s := range strings.Split(v, sep) {
+			if s = strings.TrimSpace(s); s != "" {
+				ret = append(ret, s)
+			}
+		}
+	}
+	if len(ret) == 0 {
+		return nil, &ParamError{Key: key, Type: "strings", Err: ErrParamMissing}
+	}
+	return ret, nil
+}
+
+//按sep拆分参数，拆分后为空时返回def
+func (input *ThingoInput) StringsOrDefault(key, sep string, def []string) []string {
+	if v, err := input.Strings(key, sep); err == nil {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,106 @@
+package context
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+//路由参数是单值的，命中时遮住URL、表单里的同名参数
+func TestParamValuesPrecedence(t *testing.T) {
+	req := httptest.NewRequest("POST", "/?id=2&id=3&tag=a&tag=b", strings.NewReader("id=4&tag=c&name=x"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ctx, _ := newTestContext(req)
+	ctx.Input.ParseFormOrMulitForm(1 << 20)
+	ctx.Input.SetParam("id", "1")
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{"id", []string{"1"}},
+		{"tag", []string{"a", "b"}},
+		{"name", []string{"x"}},
+		{"none", nil},
+	}
+	for _, tt := range tests {
+		if got := ctx.Input.ParamValues(tt.key); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParamValues(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+	if got := ctx.Input.Param("id"); got != "1" {
+		t.Errorf("Param(id) = %q, want 1", got)
+	}
+}
+
+func TestParamOrDefault(t *testing.T) {
+	ctx, _ := newTestContext(httptest.NewRequest("GET", "/?n=5&bad=x&b=on&d=1m&f=1.5&ids=1,2", nil))
+	in := ctx.Input
+	if v := in.IntOrDefault("n", 9); v != 5 {
+		t.Errorf("IntOrDefault(n) = %d", v)
+	}
+	if v := in.IntOrDefault("bad", 9); v != 9 {
+		t.Errorf("IntOrDefault(bad) = %d", v)
+	}
+	if v := in.Int64OrDefault("none", 9); v != 9 {
+		t.Errorf("Int64OrDefault(none) = %d", v)
+	}
+	if v := in.UintOrDefault("n", 9); v != 5 {
+		t.Errorf("UintOrDefault(n) = %d", v)
+	}
+	if v := in.FloatOrDefault("f", 9); v != 1.5 {
+		t.Errorf("FloatOrDefault(f) = %v", v)
+	}
+	if v := in.BoolOrDefault("b", false); !v {
+		t.Error("BoolOrDefault(b) = false")
+	}
+	if v := in.DurationOrDefault("d", 0); v != time.Minute {
+		t.Errorf("DurationOrDefault(d) = %v", v)
+	}
+	def := time.Unix(0, 0)
+	if v := in.TimeOrDefault("bad", "2006-01-02", def); !v.Equal(def) {
+		t.Errorf("TimeOrDefault(bad) = %v", v)
+	}
+	if v := in.StringsOrDefault("ids", ",", nil); !reflect.DeepEqual(v, []string{"1", "2"}) {
+		t.Errorf("StringsOrDefault(ids) = %v", v)
+	}
+	if v := in.StringsOrDefault("none", ",", []string{"z"}); !reflect.DeepEqual(v, []string{"z"}) {
+		t.Errorf("StringsOrDefault(none) = %v", v)
+	}
+}
+
+//参数不存在或转换失败时返回*ParamError，状态码为400
+func TestParamTypedErrors(t *testing.T) {
+	ctx, _ := newTestContext(httptest.NewRequest("GET", "/?n=-5&bad=x&empty=", nil))
+	in := ctx.Input
+	if v, err := in.Int("n"); err != nil || v != -5 {
+		t.Errorf("Int(n) = %d, %v", v, err)
+	}
+	tests := []struct {
+		name    string
+		fn      func() error
+		missing bool
+	}{
+		{"Int(bad)", func() error { _, err := in.Int("bad"); return err }, false},
+		{"Int(none)", func() error { _, err := in.Int("none"); return err }, true},
+		{"Int(empty)", func() error { _, err := in.Int("empty"); return err }, true},
+		{"Uint(n)", func() error { _, err := in.Uint("n"); return err }, false},
+		{"Float(bad)", func() error { _, err := in.Float("bad"); return err }, false},
+		{"Bool(bad)", func() error { _, err := in.Bool("bad"); return err }, false},
+		{"Duration(bad)", func() error { _, err := in.Duration("bad"); return err }, false},
+		{"Time(bad)", func() error { _, err := in.Time("bad", "2006-01-02"); return err }, false},
+	}
+	for _, tt := range tests {
+		err := tt.fn()
+		pe, ok := err.(*ParamError)
+		if !ok {
+			t.Errorf("%s: err = %v, want *ParamError", tt.name, err)
+			continue
+		}
+		if (pe.Err == ErrParamMissing) != tt.missing || ErrorStatus(err) != http.StatusBadRequest {
+			t.Errorf("%s: err = %v, status %d", tt.name, err, ErrorStatus(err))
+		}
+	}
+}
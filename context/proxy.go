@@ -0,0 +1,213 @@
+package context
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+/**
+设置信任的代理，可以是CIDR或单个IP，如"10.0.0.0/8"、"127.0.0.1"、"::1"
+只有直接连过来的一方是信任的代理时，才会使用X-Forwarded-For、Forwarded、X-Real-IP等头信息，
+否则客户端IP、协议、域名都取自连接本身
+*/
+func (cfg *ThingoConfig) SetTrustedProxies(proxies ...string) error {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return fmt.Errorf("invalid trusted proxy %q", p)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(p)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q: %v", p, err)
+		}
+		nets = append(nets, ipnet)
+	}
+	cfg.TrustedProxies = nets
+	return nil
+}
+
+//某个IP是否为信任的代理
+func (cfg *ThingoConfig) IsTrustedProxy(ip string) bool {
+	if cfg == nil {
+		return false
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, n := range cfg.TrustedProxies {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+//经过的一跳代理的信息
+type forwardedHop struct {
+	ip    string //该跳的客户端IP，没有端口
+	proto string //该跳收到的请求的协议
+	host  string //该跳收到的请求的域名
+}
+
+//按信任的代理解析出来的客户端信息
+type clientInfo struct {
+	ip     string
+	scheme string
+	host   string
+}
+
+//解析客户端的IP、协议、域名，每次请求只解析一次
+func (input *ThingoInput) client() *clientInfo {
+	if input.clientInfo != nil {
+		return input.clientInfo
+	}
+	req := input.Context.Request
+	info := &clientInfo{ip: stripPort(req.RemoteAddr), host: req.Host}
+	if req.URL.Scheme != "" {
+		info.scheme = req.URL.Scheme
+	} else if req.TLS != nil {
+		info.scheme = "https"
+	} else {
+		info.scheme = "http"
+	}
+	input.clientInfo = info
+	if !input.Context.Config.IsTrustedProxy(info.ip) {
+		return info
+	}
+
+	hops := input.forwardedHops()
+	if len(hops) == 0 {
+		if ip := stripPort(input.Header("X-Real-IP")); net.ParseIP(ip) != nil {
+			info.ip = ip
+		}
+		return info
+	}
+	//从最近的一跳往前找，第一个不是信任代理的就是客户端
+	//它的地址无效时（如“unknown”、混淆过的标识）IP仍用连接的地址，不会把后面信任的代理当成客户端
+	i := len(hops) - 1
+	for i > 0 && input.Context.Config.IsTrustedProxy(hops[i].ip) {
+		i--
+	}
+	if net.ParseIP(hops[i].ip) != nil {
+		info.ip = hops[i].ip
+	}
+	if hops[i].proto != "" {
+		info.scheme = strings.ToLower(hops[i].proto)
+	}
+	if hops[i].host != "" {
+		info.host = hops[i].host
+	}
+	return info
+}
+
+/**
+提取代理链路上的每一跳，离当前服务越近的越靠后
+有Forwarded头（RFC 7239）时优先用它，否则用X-Forwarded-For、X-Forwarded-Proto、X-Forwarded-Host
+*/
+func (input *ThingoInput) forwardedHops() []forwardedHop {
+	header := input.Context.Request.Header
+	if fwd := header.Values("Forwarded"); len(fwd) > 0 {
+		return parseForwarded(strings.Join(fwd, ","))
+	}
+	ips := input.Proxy()
+	if len(ips) == 0 {
+		return nil
+	}
+	protos := splitHeaderList(header.Values("X-Forwarded-Proto"))
+	hosts := splitHeaderList(header.Values("X-Forwarded-Host"))
+	hops := make([]forwardedHop, len(ips))
+	for i, ip := range ips {
+		hops[i] = forwardedHop{ip: stripPort(ip), proto: alignedValue(protos, i, len(ips)), host: alignedValue(hosts, i, len(ips))}
+	}
+	return hops
+}
+
+//X-Forwarded-Proto等头和X-Forwarded-For一一对应时取对应的值，否则取最近一跳设置的
+func alignedValue(vals []string, i, n int) string {
+	if len(vals) == n {
+		return vals[i]
+	}
+	if len(vals) > 0 {
+		return vals[len(vals)-1]
+	}
+	return ""
+}
+
+//把多个逗号分隔的头信息拆成一个列表
+func splitHeaderList(vals []string) []string {
+	var ret []string
+	for _, v := range vals {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				ret = append(ret, s)
+			}
+		}
+	}
+	return ret
+}
+
+//解析Forwarded头，如：for=192.0.2.60;proto=http, for="[2001:db8::17]:4711";host=example.com
+func parseForwarded(val string) []forwardedHop {
+	var hops []forwardedHop
+	for _, elem := range splitQuoted(val, ',') {
+		var hop forwardedHop
+		for _, pair := range splitQuoted(elem, ';') {
+			pos := strings.Index(pair, "=")
+			if pos < 0 {
+				continue
+			}
+			k := strings.ToLower(strings.TrimSpace(pair[:pos]))
+			v := strings.Trim(strings.TrimSpace(pair[pos+1:]), `"`)
+			switch k {
+			case "for":
+				hop.ip = stripPort(v)
+			case "proto":
+				hop.proto = v
+			case "host":
+				hop.host = v
+			}
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+//按分隔符拆分，忽略双引号里的分隔符
+func splitQuoted(s string, sep byte) []string {
+	var ret []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				ret = append(ret, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(ret, s[start:])
+}
+
+//去掉地址里的端口及IPv6的方括号，如"[::1]:80"返回"::1"
+func stripPort(addr string) string {
+	addr = strings.TrimSpace(addr)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.Trim(addr, "[]")
+}
@@ -0,0 +1,103 @@
+package context
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		tls     bool
+		ip      string
+		scheme  string
+		host    string
+	}{
+		{"direct", "1.2.3.4:5678", nil, false, "1.2.3.4", "http", "example.com"},
+		{"direct tls", "1.2.3.4:5678", nil, true, "1.2.3.4", "https", "example.com"},
+		//直接连过来的不是信任的代理时忽略所有转发的头
+		{"untrusted remote", "8.8.8.8:1", map[string]string{
+			"X-Forwarded-For":   "1.2.3.4",
+			"X-Real-IP":         "1.2.3.4",
+			"X-Forwarded-Proto": "https",
+			"X-Forwarded-Host":  "evil.com",
+			"Forwarded":         "for=1.2.3.4;proto=https;host=evil.com",
+		}, false, "8.8.8.8", "http", "example.com"},
+		{"xff", "10.0.0.1:1", map[string]string{"X-Forwarded-For": "1.2.3.4"}, false, "1.2.3.4", "http", "example.com"},
+		{"xff chain", "10.0.0.1:1", map[string]string{"X-Forwarded-For": "9.9.9.9, 1.2.3.4, 10.0.0.2"}, false, "1.2.3.4", "http", "example.com"},
+		{"xff with port", "10.0.0.1:1", map[string]string{"X-Forwarded-For": "1.2.3.4:8080"}, false, "1.2.3.4", "http", "example.com"},
+		{"xff all trusted", "10.0.0.1:1", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, false, "10.0.0.3", "http", "example.com"},
+		//第一个不是信任代理的地址无效时不能把后面的代理当成客户端
+		{"xff unknown", "10.0.0.1:1", map[string]string{"X-Forwarded-For": "1.2.3.4, unknown, 10.0.0.2"}, false, "10.0.0.1", "http", "example.com"},
+		{"xff proto host", "10.0.0.1:1", map[string]string{
+			"X-Forwarded-For":   "1.2.3.4",
+			"X-Forwarded-Proto": "HTTPS",
+			"X-Forwarded-Host":  "www.example.org:8443",
+		}, false, "1.2.3.4", "https", "www.example.org"},
+		{"xff aligned proto", "10.0.0.1:1", map[string]string{
+			"X-Forwarded-For":   "1.2.3.4, 10.0.0.2",
+			"X-Forwarded-Proto": "https, http",
+		}, false, "1.2.3.4", "https", "example.com"},
+		{"forwarded", "10.0.0.1:1", map[string]string{"Forwarded": "for=1.2.3.4;proto=https;host=a.example.org"}, false, "1.2.3.4", "https", "a.example.org"},
+		{"forwarded ipv6", "10.0.0.1:1", map[string]string{"Forwarded": `for="[2001:db8::17]:4711";proto=https`}, false, "2001:db8::17", "https", "example.com"},
+		{"forwarded quoted", "10.0.0.1:1", map[string]string{"Forwarded": `for="1.2.3.4";Host="b.example.org", For=10.0.0.2`}, false, "1.2.3.4", "http", "b.example.org"},
+		{"forwarded obfuscated", "10.0.0.1:1", map[string]string{"Forwarded": "for=1.2.3.4, for=_hidden, for=10.0.0.2"}, false, "10.0.0.1", "http", "example.com"},
+		//Forwarded优先于X-Forwarded-For
+		{"forwarded wins", "10.0.0.1:1", map[string]string{"Forwarded": "for=1.2.3.4", "X-Forwarded-For": "5.6.7.8"}, false, "1.2.3.4", "http", "example.com"},
+		{"real ip", "10.0.0.1:1", map[string]string{"X-Real-IP": "1.2.3.4"}, false, "1.2.3.4", "http", "example.com"},
+		{"real ip invalid", "10.0.0.1:1", map[string]string{"X-Real-IP": "unknown"}, false, "10.0.0.1", "http", "example.com"},
+		{"ipv6 proxy", "[::1]:1", map[string]string{"X-Forwarded-For": "2001:db8::1"}, false, "2001:db8::1", "http", "example.com"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.URL.Scheme = ""
+		req.RemoteAddr = tt.remote
+		if tt.tls {
+			req.TLS = &tls.ConnectionState{}
+		}
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		ctx, _ := newTestContext(req)
+		ctx.Config = NewThingoConfig()
+		if err := ctx.Config.SetTrustedProxies("10.0.0.0/8", "::1"); err != nil {
+			t.Fatal(err)
+		}
+		if got := ctx.Input.IP(); got != tt.ip {
+			t.Errorf("%s: IP = %q, want %q", tt.name, got, tt.ip)
+		}
+		if got := ctx.Input.Scheme(); got != tt.scheme {
+			t.Errorf("%s: Scheme = %q, want %q", tt.name, got, tt.scheme)
+		}
+		if got := ctx.Input.Host(); got != tt.host {
+			t.Errorf("%s: Host = %q, want %q", tt.name, got, tt.host)
+		}
+	}
+}
+
+func TestSetTrustedProxies(t *testing.T) {
+	cfg := NewThingoConfig()
+	if err := cfg.SetTrustedProxies("10.0.0.0/8", " 192.168.1.1 ", "fd00::/8"); err != nil {
+		t.Fatal(err)
+	}
+	for ip, want := range map[string]bool{
+		"10.1.2.3":    true,
+		"192.168.1.1": true,
+		"192.168.1.2": false,
+		"fd00::1":     true,
+		"::1":         false,
+		"unknown":     false,
+	} {
+		if got := cfg.IsTrustedProxy(ip); got != want {
+			t.Errorf("IsTrustedProxy(%q) = %v, want %v", ip, got, want)
+		}
+	}
+	for _, p := range []string{"10.0.0.0/33", "localhost", ""} {
+		if err := cfg.SetTrustedProxies(p); err == nil {
+			t.Errorf("SetTrustedProxies(%q) should fail", p)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package context
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+//把数据编码后输出，RegisterRenderer注册后可参与内容协商
+type Renderer interface {
+	ContentType() string                        //输出的Content-Type，如“application/json; charset=utf-8”
+	Render(w io.Writer, data interface{}) error //把data编码后写入w
+}
+
+//按类型注册的输出方式，list为注册的顺序，也是协商时的默认顺序
+var renderers = struct {
+	sync.RWMutex
+	m    map[string]Renderer
+	list []string
+}{m: make(map[string]Renderer)}
+
+func init() {
+	RegisterRenderer("application/json", jsonRenderer{})
+	RegisterRenderer("application/xml", xmlRenderer{})
+	RegisterRenderer("text/plain", textRenderer{})
+	RegisterRenderer("application/yaml", yamlRenderer{})
+	RegisterRenderer("application/msgpack", msgPackRenderer{})
+	RegisterRenderer("text/csv", csvRenderer{})
+}
+
+//注册某个类型的输出方式，如"application/msgpack"，已有的会被覆盖
+func RegisterRenderer(mediaType string, r Renderer) {
+	mediaType = strings.ToLower(mediaType)
+	renderers.Lock()
+	defer renderers.Unlock()
+	if _, ok := renderers.m[mediaType]; !ok {
+		renderers.list = append(renderers.list, mediaType)
+	}
+	renderers.m[mediaType] = r
+}
+
+//提取某个类型的输出方式
+func LookupRenderer(mediaType string) Renderer {
+	renderers.RLock()
+	defer renderers.RUnlock()
+	return renderers.m[strings.ToLower(mediaType)]
+}
+
+//所有注册过的类型，按注册的顺序
+func RendererTypes() []string {
+	renderers.RLock()
+	defer renderers.RUnlock()
+	return append([]string{}, renderers.list...)
+}
+
+type jsonRenderer struct{}
+
+func (jsonRenderer) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+func (jsonRenderer) Render(w io.Writer, data interface{}) error {
+	return json.NewEncoder(w).Encode(data)
+}
+
+type xmlRenderer struct{}
+
+func (xmlRenderer) ContentType() string {
+	return "application/xml; charset=utf-8"
+}
+
+func (xmlRenderer) Render(w io.Writer, data interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(data)
+}
+
+//纯文本，[]byte、string原样输出，其他的用fmt.Sprint
+type textRenderer struct{}
+
+func (textRenderer) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+func (textRenderer) Render(w io.Writer, data interface{}) error {
+	var err error
+	switch v := data.(type) {
+	case []byte:
+		_, err = w.Write(v)
+	case string:
+		_, err = io.WriteString(w, v)
+	default:
+		_, err = fmt.Fprint(w, v)
+	}
+	return err
+}
+
+/**
+按Accept头协商输出的类型，再用对应的Renderer输出
+候选的类型依次取：参数offers、路由上配置的Produces、所有注册过的类型
+都不被客户端接受时，状态码置为406，并返回错误
+客户端最想要的类型编码失败时（如xml不支持map），依次尝试后面可以接受的类型，最后尝试offers里的json，
+都失败时同RenderWith，状态码置为500
+*/
+func (output *ThingoOuput) Render(data interface{}, offers ...string) error {
+	if len(offers) == 0 {
+		offers = output.Produces
+	}
+	if len(offers) == 0 {
+		offers = RendererTypes()
+	}
+	accepted := output.Context.Input.acceptedOffers(offers)
+	output.AddVary("Accept")
+	if len(accepted) == 0 {
+		output.SetStatus(http.StatusNotAcceptable)
+		output.AddHeader("Content-Type", "text/plain; charset=utf-8")
+		output.SetBody([]byte(http.StatusText(http.StatusNotAcceptable)))
+		return NewHTTPError(http.StatusNotAcceptable, "none of %s is acceptable", strings.Join(offers, ", "))
+	}
+	candidates := accepted
+	if !containsMediaType(accepted, "application/json") {
+		for _, offer := range offers {
+			if offerMediaType(offer) == "application/json" {
+				candidates = append(append([]string{}, accepted...), offer)
+				break
+			}
+		}
+	}
+	var firstErr error
+	for _, mediaType := range candidates {
+		r := LookupRenderer(mediaType)
+		if r == nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("no renderer registered for %q", mediaType)
+			}
+			continue
+		}
+		buf := new(bytes.Buffer)
+		if err := r.Render(buf, data); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		output.AddHeader("Content-Type", r.ContentType())
+		output.SetBody(buf.Bytes())
+		return nil
+	}
+	return output.renderFailed(firstErr)
+}
+
+//列表里是否有某个类型，不比较参数
+func containsMediaType(offers []string, mediaType string) bool {
+	for _, offer := range offers {
+		if offerMediaType(offer) == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+//用指定类型的Renderer输出，不做协商
+func (output *ThingoOuput) RenderAs(mediaType string, data interface{}) error {
+	r := LookupRenderer(mediaType)
+	if r == nil {
+		return fmt.Errorf("no renderer registered for %q", mediaType)
+	}
+	return output.RenderWith(r, data)
+}
+
+/**
+用指定的Renderer输出，所有的RenderXxx最终都走这里
+编码失败时状态码置为500，body为错误信息，并返回状态码为500的错误
+*/
+func (output *ThingoOuput) RenderWith(r Renderer, data interface{}) error {
+	buf := new(bytes.Buffer)
+	if err := r.Render(buf, data); err != nil {
+		return output.renderFailed(err)
+	}
+	output.AddHeader("Content-Type", r.ContentType())
+	output.SetBody(buf.Bytes())
+	return nil
+}
+
+//编码失败时输出500及错误信息
+func (output *ThingoOuput) renderFailed(err error) error {
+	output.SetStatus(http.StatusInternalServerError)
+	output.AddHeader("Content-Type", "text/plain; charset=utf-8")
+	output.SetBody([]byte(err.Error()))
+	return &ThingoHTTPError{Status: http.StatusInternalServerError, Msg: "render failed", Err: err}
+}
+
+//输出xml
+func (output *ThingoOuput) RenderXml(data interface{}) error {
+	return output.RenderWith(xmlRenderer{}, data)
+}
+
+//输出纯文本，[]byte、string原样输出，其他的用fmt.Sprint
+func (output *ThingoOuput) RenderText(data interface{}) error {
+	return output.RenderWith(textRenderer{}, data)
+}
+
+//输出yaml
+func (output *ThingoOuput) RenderYaml(data interface{}) error {
+	return output.RenderWith(yamlRenderer{}, data)
+}
+
+//输出MessagePack
+func (output *ThingoOuput) RenderMsgPack(data interface{}) error {
+	return output.RenderWith(msgPackRenderer{}, data)
+}
+
+//输出csv，rows可以是二维切片、结构体切片、CsvRowFunc、<-chan []string，后两种会按流式逐行输出
+func (output *ThingoOuput) RenderCsv(rows interface{}) error {
+	switch rows.(type) {
+	case CsvRowFunc, func() ([]string, error), <-chan []string:
+		return output.StreamWith(csvRenderer{}, rows)
+	}
+	return output.RenderWith(csvRenderer{}, rows)
+}
+
+/**
+用指定的Renderer按流式输出，编码的过程中直接发给客户端，适合大量数据的导出
+开始输出后出错时只能中断，返回的错误状态码为500
+*/
+func (output *ThingoOuput) StreamWith(r Renderer, data interface{}) error {
+	if !output.Streaming {
+		output.AddHeader("Content-Type", r.ContentType())
+	}
+	w := &flushWriter{output: output}
+	err := r.Render(w, data)
+	output.Flush()
+	if err != nil {
+		return &ThingoHTTPError{Status: http.StatusInternalServerError, Msg: "render failed", Err: err}
+	}
+	return nil
+}
+
+//流式输出时每写满一段就刷新一次，避免数据一直留在缓冲区里
+type flushWriter struct {
+	output  *ThingoOuput
+	pending int
+}
+
+//攒够这么多字节刷新一次
+const streamFlushSize = 32 << 10
+
+func (w *flushWriter) Write(p []byte) (int, error) {
+	n, err := w.output.Write(p)
+	w.pending += n
+	if w.pending >= streamFlushSize {
+		w.output.Flush()
+		w.pending = 0
+	}
+	return n, err
+}
+
+//往Vary头里追加一项，已有时不重复添加
+func (output *ThingoOuput) AddVary(field string) {
+	header := output.Context.ResponseWriter.Header()
+	for _, v := range header.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(f), field) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", field)
+}
+
+//编码时的一个键值对，给map、结构体用
+type namedField struct {
+	key string
+	val reflect.Value
+}
+
+/**
+按标签提取结构体可导出的字段，标签依次取tag、json，都没有时用naming处理字段名
+支持“-”及“omitempty”，没有标签的匿名结构体字段会被展开
+*/
+func structFields(v reflect.Value, tag string, naming func(string) string) []namedField {
+	var ret []namedField
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		name, opts := sf.Tag.Get(tag), ""
+		if name == "" {
+			name = sf.Tag.Get("json")
+		}
+		if pos := strings.Index(name, ","); pos >= 0 {
+			name, opts = name[:pos], name[pos:]
+		}
+		if name == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		if sf.Anonymous && name == "" {
+			if inner := reflect.Indirect(fv); inner.IsValid() && inner.Kind() == reflect.Struct {
+				ret = append(ret, structFields(inner, tag, naming)...)
+			}
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if strings.Contains(opts, ",omitempty") && fv.IsZero() {
+			continue
+		}
+		if name == "" {
+			name = naming(sf.Name)
+		}
+		ret = append(ret, namedField{key: name, val: fv})
+	}
+	return ret
+}
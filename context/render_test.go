@@ -0,0 +1,104 @@
+package context
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccepts(t *testing.T) {
+	offers := []string{"application/json", "application/xml", "text/html; charset=utf-8"}
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", "application/json"},
+		{"application/xml", "application/xml"},
+		{"Application/XML", "application/xml"},
+		{"text/html", "text/html; charset=utf-8"},
+		{"application/json;q=0.5, application/xml", "application/xml"},
+		{"application/json;q=0.5, application/xml;q=0.9", "application/xml"},
+		//权重相同时按offers的顺序
+		{"application/xml, application/json", "application/json"},
+		{"text/*", "text/html; charset=utf-8"},
+		{"*/*", "application/json"},
+		{"*", "application/json"},
+		//最具体的一项决定权重
+		{"application/*;q=0.1, application/xml, */*;q=0.5", "application/xml"},
+		{"*/*, application/json;q=0", "application/xml"},
+		{"application/json;q=0", ""},
+		{"image/png", ""},
+		{"application/json;q=2, text/html", "text/html; charset=utf-8"},
+		{"not a type, text/html", "text/html; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		ctx, _ := newTestContext(req)
+		if got := ctx.Input.Accepts(offers...); got != tt.want {
+			t.Errorf("Accept %q: got %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	type item struct {
+		Name string `json:"name" xml:"name"`
+	}
+	tests := []struct {
+		name     string
+		accept   string
+		produces []string
+		data     interface{}
+		status   int
+		ctype    string
+		body     string
+	}{
+		{"default json", "", nil, item{"a"}, 0, "application/json", `{"name":"a"}`},
+		{"xml", "application/xml", nil, item{"a"}, 0, "application/xml", "<item><name>a</name></item>"},
+		{"q-values", "application/json;q=0.2, application/yaml", nil, map[string]string{"name": "a"}, 0, "application/yaml", "name: a"},
+		{"wildcard", "text/*", nil, "hi", 0, "text/plain", "hi"},
+		{"produces", "*/*", []string{"application/xml"}, item{"a"}, 0, "application/xml", "<name>a</name>"},
+		{"not acceptable", "image/png", nil, item{"a"}, http.StatusNotAcceptable, "text/plain", "Not Acceptable"},
+		{"produces not acceptable", "application/json", []string{"application/xml"}, item{"a"}, http.StatusNotAcceptable, "text/plain", "Not Acceptable"},
+		//xml不能编码map，改用下一个可以接受的类型
+		{"fallback next", "application/xml, application/yaml;q=0.5", nil, map[string]string{"name": "a"}, 0, "application/yaml", "name: a"},
+		//可以接受的都编码失败时用json
+		{"fallback json", "application/xml", nil, map[string]string{"name": "a"}, 0, "application/json", `{"name":"a"}`},
+		{"fallback json csv", "text/csv", nil, map[string]string{"name": "a"}, 0, "application/json", `{"name":"a"}`},
+		//配置了Produces且没有json时返回500
+		{"fallback failed", "application/xml", []string{"application/xml"}, map[string]string{"name": "a"}, http.StatusInternalServerError, "text/plain", "unsupported type"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		ctx, rec := newTestContext(req)
+		ctx.Output.Produces = tt.produces
+		status := http.StatusOK
+		if err := ctx.Output.Render(tt.data); err != nil {
+			status = ErrorStatus(err)
+		}
+		want := tt.status
+		if want == 0 {
+			want = http.StatusOK
+		}
+		ctx.Output.Send()
+		if status != want || rec.Code != want {
+			t.Errorf("%s: Render status %d, response %d, want %d", tt.name, status, rec.Code, want)
+		}
+		if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.ctype) {
+			t.Errorf("%s: Content-Type = %q, want %s", tt.name, got, tt.ctype)
+		}
+		if got := rec.Body.String(); !strings.Contains(got, tt.body) {
+			t.Errorf("%s: body = %q, want %q", tt.name, got, tt.body)
+		}
+		if got := rec.Header().Get("Vary"); got != "Accept" {
+			t.Errorf("%s: Vary = %q", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package context
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+//包装原始的http.ResponseWriter，记录最终的状态码及写出的字节数
+type ThingoResponseWriter struct {
+	http.ResponseWriter
+	status      int   //最终写出的状态码
+	size        int64 //已写出的body字节数
+	wroteHeader bool  //是否已写出头信息
+	committed   bool  //Output是否已经输出完了
+}
+
+//重置为包装新的http.ResponseWriter
+func (w *ThingoResponseWriter) Reset(rw http.ResponseWriter) {
+	w.ResponseWriter = rw
+	w.status = 0
+	w.size = 0
+	w.wroteHeader = false
+	w.committed = false
+}
+
+//写出头信息，只有第一次有效
+func (w *ThingoResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+//写出body，没写过头信息时默认200
+func (w *ThingoResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+//实现io.ReaderFrom，原始对象支持时可以用上sendfile等
+func (w *ThingoResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(w.ResponseWriter, r)
+	}
+	w.size += n
+	return n, err
+}
+
+//最终写出的状态码，还没写出时返回0，已经输出完但没写过状态码时为默认的200
+func (w *ThingoResponseWriter) Status() int {
+	if w.status == 0 && w.committed {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+//已写出的body字节数
+func (w *ThingoResponseWriter) Size() int64 {
+	return w.size
+}
+
+//是否已写出头信息
+func (w *ThingoResponseWriter) Written() bool {
+	return w.wroteHeader
+}
+
+//实现http.Flusher
+func (w *ThingoResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.wroteHeader {
+			w.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+//实现http.Hijacker，用于WebSocket等接管连接的场景，接管后状态码记为101
+func (w *ThingoResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("http.Hijacker not supported")
+	}
+	conn, brw, err := h.Hijack()
+	if err == nil {
+		w.wroteHeader = true
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+//实现http.CloseNotifier
+func (w *ThingoResponseWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	return nil
+}
+
+//返回被包装的原始对象，给http.ResponseController用
+func (w *ThingoResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,207 @@
+package context
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//SSE连接已经关闭
+var ErrSSEClosed = errors.New("sse: stream closed")
+
+//一条SSE事件
+type SSEEvent struct {
+	Id    string        //事件ID，客户端重连时通过Last-Event-ID带回来
+	Event string        //事件类型，为空时客户端按“message”处理
+	Data  interface{}   //数据，string、[]byte原样发送，其他的编码成json
+	Retry time.Duration //客户端断线后重连的间隔，为0时不发送
+}
+
+/**
+SSE（Server-Sent Events）的输出对象，由ctx.SSE()获取，如：
+	sse := ctx.SSE()
+	sse.Heartbeat(15 * time.Second)
+	for {
+		select {
+		case <-sse.Done():
+			return
+		case msg := <-ch:
+			if err := sse.Send(SSEEvent{Id: msg.Id, Data: msg}); err != nil {
+				return
+			}
+		}
+	}
+
+可以在多个goroutine里同时调用，控制层返回后、输出之前自动关闭
+*/
+type ThingoSSE struct {
+	ctx    *ThingoContext
+	mu     sync.Mutex
+	err    error         //第一次写入失败的错误，之后都返回它
+	stop   chan struct{} //关闭时停止心跳
+	closed bool
+}
+
+/**
+开始SSE输出，设置相关的头信息并立即发出，同一个请求里多次调用返回同一个对象
+之后不能再用Output输出其他内容
+*/
+func (ThingoCtx *ThingoContext) SSE() *ThingoSSE {
+	if ThingoCtx.sse != nil {
+		return ThingoCtx.sse
+	}
+	s := &ThingoSSE{ctx: ThingoCtx, stop: make(chan struct{})}
+	ThingoCtx.sse = s
+	ThingoCtx.OnRelease(s.Close)
+	output := ThingoCtx.Output
+	if !output.Started {
+		header := ThingoCtx.ResponseWriter.Header()
+		header.Set("Content-Type", "text/event-stream; charset=utf-8")
+		header.Set("Cache-Control", "no-cache")
+		header.Set("Connection", "keep-alive")
+		header.Set("X-Accel-Buffering", "no") //关掉nginx的缓冲
+		header.Del("Content-Length")
+		header.Del("Expires")
+		output.Status = http.StatusOK
+		output.Body = nil
+	}
+	output.Flush()
+	return s
+}
+
+//客户端重连时带过来的最后一个事件ID，没有时为空
+func (s *ThingoSSE) LastEventId() string {
+	if id := s.ctx.Input.Header("Last-Event-ID"); id != "" {
+		return id
+	}
+	//EventSource的polyfill不能设置头信息时用参数传
+	return s.ctx.Input.QueryValues().Get("lastEventId")
+}
+
+//客户端断开或请求被取消时关闭的channel
+func (s *ThingoSSE) Done() <-chan struct{} {
+	return s.ctx.Request.Context().Done()
+}
+
+//发送一条事件，客户端已经断开时返回错误
+func (s *ThingoSSE) Send(ev SSEEvent) error {
+	var b strings.Builder
+	if ev.Id != "" {
+		b.WriteString("id: " + sseField(ev.Id) + "\n")
+	}
+	if ev.Event != "" {
+		b.WriteString("event: " + sseField(ev.Event) + "\n")
+	}
+	if ev.Retry > 0 {
+		fmt.Fprintf(&b, "retry: %d\n", ev.Retry/time.Millisecond)
+	}
+	var data string
+	switch v := ev.Data.(type) {
+	case nil:
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		data = string(buf)
+	}
+	if ev.Data != nil || b.Len() == 0 {
+		data = strings.Replace(data, "\r\n", "\n", -1)
+		for _, line := range strings.Split(data, "\n") {
+			b.WriteString("data: " + strings.TrimSuffix(line, "\r") + "\n")
+		}
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+//只发送数据的事件
+func (s *ThingoSSE) SendData(data interface{}) error {
+	return s.Send(SSEEvent{Data: data})
+}
+
+//发送一行注释，客户端会忽略，一般用来保持连接
+func (s *ThingoSSE) Comment(text string) error {
+	return s.write(": " + sseField(text) + "\n\n")
+}
+
+/**
+每隔interval发送一次注释作为心跳，避免连接被代理断开
+客户端断开、请求结束或调用Close后自动停止
+*/
+func (s *ThingoSSE) Heartbeat(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	//先取出来，请求结束后ctx会被放回池子里复用，不能再在goroutine里读ctx.Request
+	done := s.Done()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if s.Comment("ping") != nil {
+					return
+				}
+			case <-s.stop:
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+//关闭，停止心跳，之后的发送都返回ErrSSEClosed
+func (s *ThingoSSE) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.stop)
+	if s.err == nil {
+		s.err = ErrSSEClosed
+	}
+}
+
+//关闭SSE输出，没有调用过SSE()时什么也不做，由ThingoHandler在输出前自动调用
+func (ThingoCtx *ThingoContext) CloseSSE() {
+	if ThingoCtx.sse != nil {
+		ThingoCtx.sse.Close()
+	}
+}
+
+//写入并立即发出去
+func (s *ThingoSSE) write(data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if err := s.ctx.Request.Context().Err(); err != nil {
+		s.err = err
+		return err
+	}
+	if _, err := s.ctx.Output.Write([]byte(data)); err != nil {
+		s.err = err
+		return err
+	}
+	s.ctx.Output.Flush()
+	return nil
+}
+
+//id、event等字段里不能有换行
+func sseField(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,199 @@
+package context
+
+import (
+	"encoding"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//输出yaml，只做编码，结构体字段名依次取yaml、json标签，都没有时用小写的字段名
+type yamlRenderer struct{}
+
+func (yamlRenderer) ContentType() string {
+	return "application/yaml; charset=utf-8"
+}
+
+func (yamlRenderer) Render(w io.Writer, data interface{}) error {
+	e := &yamlEncoder{w: w}
+	e.encode(reflect.ValueOf(data))
+	return e.err
+}
+
+//把数据编码成块格式的yaml
+type yamlEncoder struct {
+	w   io.Writer
+	err error
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+func (e *yamlEncoder) write(s string) {
+	if e.err == nil {
+		_, e.err = io.WriteString(e.w, s)
+	}
+}
+
+//编码顶层的值
+func (e *yamlEncoder) encode(v reflect.Value) {
+	v = yamlIndirect(v)
+	if s, ok := e.scalar(v); ok {
+		e.write(s + "\n")
+		return
+	}
+	e.block(v, 0, false)
+}
+
+//去掉指针及接口
+func yamlIndirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		if v.Type().Implements(textMarshalerType) && v.Kind() == reflect.Ptr {
+			return v
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+//标量时返回编码后的字符串，map、切片、结构体返回false
+func (e *yamlEncoder) scalar(v reflect.Value) (string, bool) {
+	if !v.IsValid() {
+		return "null", true
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339Nano), true
+	}
+	if v.Type().Implements(textMarshalerType) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			e.err = err
+			return "", true
+		}
+		return yamlString(string(b)), true
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		switch {
+		case math.IsNaN(f):
+			return ".nan", true
+		case math.IsInf(f, 1):
+			return ".inf", true
+		case math.IsInf(f, -1):
+			return "-.inf", true
+		}
+		return strconv.FormatFloat(f, 'g', -1, v.Type().Bits()), true
+	case reflect.String:
+		return yamlString(v.String()), true
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return yamlString(string(v.Bytes())), true
+		}
+		if v.Len() == 0 {
+			return "[]", true
+		}
+	case reflect.Array:
+		if v.Len() == 0 {
+			return "[]", true
+		}
+	case reflect.Map, reflect.Struct:
+		if len(e.fields(v)) == 0 {
+			return "{}", true
+		}
+	default:
+		e.err = fmt.Errorf("yaml: unsupported type %s", v.Type())
+		return "", true
+	}
+	return "", false
+}
+
+//按块格式输出map、切片、结构体，inline表示第一行已经在“- ”后面，不用缩进
+func (e *yamlEncoder) block(v reflect.Value, indent int, inline bool) {
+	pad := strings.Repeat(" ", indent)
+	first := true
+	prefix := func() string {
+		if first && inline {
+			first = false
+			return ""
+		}
+		first = false
+		return pad
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len() && e.err == nil; i++ {
+			item := yamlIndirect(v.Index(i))
+			if s, ok := e.scalar(item); ok {
+				e.write(prefix() + "- " + s + "\n")
+				continue
+			}
+			e.write(prefix() + "- ")
+			e.block(item, indent+2, true)
+		}
+	default:
+		for _, f := range e.fields(v) {
+			if e.err != nil {
+				return
+			}
+			val := yamlIndirect(f.val)
+			if s, ok := e.scalar(val); ok {
+				e.write(prefix() + yamlString(f.key) + ": " + s + "\n")
+				continue
+			}
+			e.write(prefix() + yamlString(f.key) + ":\n")
+			e.block(val, indent+2, false)
+		}
+	}
+}
+
+//提取map、结构体的键值对，map按键排序，结构体按字段顺序
+func (e *yamlEncoder) fields(v reflect.Value) []namedField {
+	var ret []namedField
+	if v.Kind() == reflect.Map {
+		for _, k := range v.MapKeys() {
+			ret = append(ret, namedField{key: fmt.Sprint(yamlIndirect(k).Interface()), val: v.MapIndex(k)})
+		}
+		sort.Slice(ret, func(i, j int) bool { return ret[i].key < ret[j].key })
+		return ret
+	}
+	return structFields(v, "yaml", func(s string) string { return strings.ToLower(s) })
+}
+
+//字符串需要时加上双引号，避免被当成数字、布尔值或yaml的语法
+func yamlString(s string) string {
+	if s == "" {
+		return `""`
+	}
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off", "y", "n", ".nan", ".inf", "-.inf", "=", "<<":
+		return strconv.Quote(s)
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.Quote(s)
+	}
+	//数字开头的可能被解析成十六进制、二进制、带下划线的数字、六十进制或日期，都加上引号
+	if strings.ContainsAny(s[:1], "0123456789+-?:,[]{}#&*!|>'\"%@` ") || strings.HasSuffix(s, " ") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return strconv.Quote(s)
+	}
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f || r == '\u2028' || r == '\u2029' || r == '\ufeff' {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
@@ -0,0 +1,183 @@
+package context
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+)
+
+func yamlRender(t *testing.T, data interface{}) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := (yamlRenderer{}).Render(&buf, data); err != nil {
+		t.Fatalf("Render(%#v) failed: %v", data, err)
+	}
+	return buf.String()
+}
+
+type yamlBase struct {
+	ID int `json:"id"`
+}
+
+type yamlItem struct {
+	yamlBase
+	Name  string            `yaml:"name" json:"title"`
+	Tags  []string          `json:"tags"`
+	Meta  map[string]string `yaml:"meta,omitempty"`
+	Sub   *yamlBase
+	Raw   []byte
+	Skip  string `yaml:"-"`
+	Empty struct{}
+}
+
+//字符串在可能被解析成其他类型或yaml语法时加上双引号
+func TestYAMLStrings(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{"中文", "中文"},
+		{"it's", "it's"},
+		{"a:b", "a:b"},
+		{"x!", "x!"},
+		{"v1.0", "v1.0"},
+		{"", `""`},
+		{" ", `" "`},
+		//布尔值、null及特殊的浮点数
+		{"true", `"true"`},
+		{"Yes", `"Yes"`},
+		{"off", `"off"`},
+		{"n", `"n"`},
+		{"null", `"null"`},
+		{"~", `"~"`},
+		{".NaN", `".NaN"`},
+		{"-.inf", `"-.inf"`},
+		{"=", `"="`},
+		{"<<", `"<<"`},
+		//看起来像数字或日期的
+		{"123", `"123"`},
+		{"1e3", `"1e3"`},
+		{".5", `".5"`},
+		{"+1", `"+1"`},
+		{"0x1F", `"0x1F"`},
+		{"0b101", `"0b101"`},
+		{"1_000", `"1_000"`},
+		{"1:20", `"1:20"`},
+		{"2026-01-02", `"2026-01-02"`},
+		//yaml的语法字符
+		{"-dash", `"-dash"`},
+		{" lead", `" lead"`},
+		{"trail ", `"trail "`},
+		{"a: b", `"a: b"`},
+		{"key:", `"key:"`},
+		{"a #b", `"a #b"`},
+		{"#c", `"#c"`},
+		{"@at", `"@at"`},
+		{"!tag", `"!tag"`},
+		{"%x", `"%x"`},
+		{"[1]", `"[1]"`},
+		{"{a}", `"{a}"`},
+		{"*ref", `"*ref"`},
+		{"|", `"|"`},
+		//控制字符及换行转义
+		{"line\nbreak", `"line\nbreak"`},
+		{"tab\there", `"tab\there"`},
+		{"nul\x00", `"nul\x00"`},
+		{"\u2028", `"\u2028"`},
+		{"\ufeffbom", `"\ufeffbom"`},
+	}
+	for _, tt := range tests {
+		if got := yamlRender(t, tt.in); got != tt.want+"\n" {
+			t.Errorf("encode %q = %q, want %q", tt.in, got, tt.want+"\n")
+		}
+	}
+}
+
+func TestYAMLScalars(t *testing.T) {
+	var nilPtr *int
+	n := 5
+	tests := []struct {
+		data interface{}
+		want string
+	}{
+		{nil, "null"},
+		{nilPtr, "null"},
+		{&n, "5"},
+		{true, "true"},
+		{false, "false"},
+		{-3, "-3"},
+		{int8(math.MinInt8), "-128"},
+		{uint64(math.MaxUint64), "18446744073709551615"},
+		{1.5, "1.5"},
+		{1e21, "1e+21"},
+		{float32(0.1), "0.1"},
+		{math.NaN(), ".nan"},
+		{math.Inf(1), ".inf"},
+		{math.Inf(-1), "-.inf"},
+		{[]byte("hi"), "hi"},
+		{[]byte("123"), `"123"`},
+		{time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), "2026-01-02T03:04:05Z"},
+		{[]int{}, "[]"},
+		{map[string]int{}, "{}"},
+		{struct{}{}, "{}"},
+	}
+	for _, tt := range tests {
+		if got := yamlRender(t, tt.data); got != tt.want+"\n" {
+			t.Errorf("encode %#v = %q, want %q", tt.data, got, tt.want+"\n")
+		}
+	}
+}
+
+func TestYAMLBlocks(t *testing.T) {
+	tests := []struct {
+		name string
+		data interface{}
+		want string
+	}{
+		{
+			"map keys sorted",
+			map[string]interface{}{"b": 1, "a": "x", "c": nil, "true": 1.5},
+			"a: x\nb: 1\nc: null\n\"true\": 1.5\n",
+		},
+		{
+			"nested list and map",
+			map[string]interface{}{"a": []interface{}{1, "x", map[string]int{"k": 2, "j": 1}, []int{}, map[string]int{}}},
+			"a:\n  - 1\n  - x\n  - j: 1\n    k: 2\n  - []\n  - {}\n",
+		},
+		{
+			"list of lists",
+			[]interface{}{[]int{1, 2}, map[string]interface{}{"x": []int{3}}},
+			"- - 1\n  - 2\n- x:\n    - 3\n",
+		},
+		{
+			"map of maps",
+			map[int]map[string]int{2: {"b": 2}, 1: {"a": 1}},
+			"\"1\":\n  a: 1\n\"2\":\n  b: 2\n",
+		},
+		{
+			//嵌入的结构体展开，字段名依次取yaml、json标签，没有时用小写的字段名
+			"struct",
+			yamlItem{yamlBase: yamlBase{ID: 1}, Name: "n", Tags: []string{"a", "b"}, Sub: &yamlBase{ID: 2}, Raw: []byte("hi"), Skip: "x"},
+			"id: 1\nname: \"n\"\ntags:\n  - a\n  - b\nsub:\n  id: 2\nraw: hi\nempty: {}\n",
+		},
+		{
+			"struct in list",
+			[]*yamlBase{{ID: 1}, nil},
+			"- id: 1\n- null\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := yamlRender(t, tt.data); got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestYAMLUnsupported(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (yamlRenderer{}).Render(&buf, map[string]interface{}{"c": make(chan int)}); err == nil {
+		t.Error("encode chan should fail")
+	}
+}
@@ -9,11 +9,14 @@ import (
 	"fmt"
 	"github.com/liuyongshuai/negoutils/convertutils"
 	"github.com/liuyongshuai/thingo/context"
+	"github.com/liuyongshuai/thingo/websocket"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"time"
 )
 
 type ThingoControllerInterface interface {
@@ -50,6 +53,7 @@ func (c *ThingoController) Init(ctx *context.ThingoContext, app interface{}, tpl
 	c.TplData["SERVER_REQUEST_URI"] = ctx.Input.URI()
 	c.TplData["REQUEST_DOMAIN"] = ctx.Input.Domain()
 	c.TplData["REQUEST_SITE"] = ctx.Input.Site()
+	c.TplData["CSP_NONCE"] = ctx.CSPNonce
 	for k, v := range tplInitData {
 		c.TplData[k] = v
 	}
@@ -112,6 +116,31 @@ func (c *ThingoController) RenderJsonp(data interface{}, callback ...string) err
 	return c.Ctx.Output.RenderJsonp(data, callback...)
 }
 
+//返回xml数据
+func (c *ThingoController) RenderXml(data interface{}) error {
+	return c.Ctx.Output.RenderXml(data)
+}
+
+//返回纯文本
+func (c *ThingoController) RenderText(data interface{}) error {
+	return c.Ctx.Output.RenderText(data)
+}
+
+//返回yaml数据
+func (c *ThingoController) RenderYaml(data interface{}) error {
+	return c.Ctx.Output.RenderYaml(data)
+}
+
+//返回MessagePack数据
+func (c *ThingoController) RenderMsgPack(data interface{}) error {
+	return c.Ctx.Output.RenderMsgPack(data)
+}
+
+//返回csv数据，rows可以是二维切片、结构体切片、context.CsvRowFunc、<-chan []string
+func (c *ThingoController) RenderCsv(rows interface{}) error {
+	return c.Ctx.Output.RenderCsv(rows)
+}
+
 //渲染html模板
 func (c *ThingoController) RenderHtml() error {
 	buf := new(bytes.Buffer)
@@ -124,6 +153,80 @@ func (c *ThingoController) RenderHtml() error {
 	return err
 }
 
+/**
+按Accept头协商输出的类型，可以是json、xml、纯文本、注册过的Renderer，设置了TplName时还可以是html模板
+路由上配置了Produces时只在其中选择，都不被客户端接受时状态码为406并返回错误
+输出html时，data为map的会合并到TplData里，其他的放在TplData["Data"]里
+*/
+func (c *ThingoController) Render(data interface{}) error {
+	offers := c.Ctx.Output.Produces
+	if len(offers) == 0 {
+		offers = context.RendererTypes()
+		if c.TplName != "" {
+			offers = append([]string{"text/html"}, offers...)
+		}
+	}
+	mediaType := c.Ctx.Input.Accepts(offers...)
+	if pos := strings.Index(mediaType, ";"); pos >= 0 {
+		mediaType = mediaType[:pos]
+	}
+	if !strings.EqualFold(strings.TrimSpace(mediaType), "text/html") {
+		return c.Ctx.Output.Render(data, offers...)
+	}
+	switch d := data.(type) {
+	case nil:
+	case map[interface{}]interface{}:
+		c.AddTplDatas(d)
+	case map[string]interface{}:
+		for k, v := range d {
+			c.TplData[k] = v
+		}
+	default:
+		c.TplData["Data"] = data
+	}
+	c.Ctx.Output.AddVary("Accept")
+	c.AddHeader("Content-Type", "text/html; charset=utf-8")
+	return c.RenderHtml()
+}
+
+//输出本地的文件，支持Range及条件请求，见ThingoOuput.ServeFile
+func (c *ThingoController) ServeFile(path string) error {
+	return c.Ctx.Output.ServeFile(path)
+}
+
+//以附件的形式下载本地文件，filename为下载时的文件名，默认为path里的文件名
+func (c *ThingoController) Download(path string, filename ...string) error {
+	return c.Ctx.Output.Download(path, filename...)
+}
+
+/**
+把当前请求升级为WebSocket连接，opt可以为nil
+握手失败时错误信息已经在Output里，连接会在请求结束时自动关闭，要在Run返回前用完
+*/
+func (c *ThingoController) Upgrade(opt *websocket.Options) (*websocket.Conn, error) {
+	return websocket.Upgrade(c.Ctx, opt)
+}
+
+//设置本次响应的缓存策略，见context.CachePolicy
+func (c *ThingoController) SetCachePolicy(p context.CachePolicy) {
+	c.Ctx.Output.SetCachePolicy(p)
+}
+
+//设置ETag，tag不用带引号，weak为true时为弱ETag
+func (c *ThingoController) SetETag(tag string, weak bool) {
+	c.Ctx.Output.SetETag(tag, weak)
+}
+
+//设置Last-Modified
+func (c *ThingoController) SetLastModified(t time.Time) {
+	c.Ctx.Output.SetLastModified(t)
+}
+
+//按已设置的ETag、Last-Modified判断客户端的缓存是否有效，有效时会输出304，不用再生成body
+func (c *ThingoController) NotModified() bool {
+	return c.Ctx.Output.NotModified()
+}
+
 //设置响应的状态值
 func (c *ThingoController) SetStatus(status int) {
 	c.Ctx.Output.SetStatus(status)
@@ -158,6 +261,16 @@ func (c *ThingoController) GetParam(key string, defaultVal ...interface{}) conve
 	return convertutils.MakeElemType("")
 }
 
+//按struct tag把请求参数填充到结构体里并校验，见ThingoInput.Bind
+func (c *ThingoController) Bind(dst interface{}) error {
+	return c.Ctx.Input.Bind(dst)
+}
+
+//按Content-Type把请求body解码到dst里并校验，见ThingoInput.DecodeBody
+func (c *ThingoController) DecodeBody(dst interface{}) error {
+	return c.Ctx.Input.DecodeBody(dst)
+}
+
 //获取上传文件
 func (c *ThingoController) GetFile(key string) (multipart.File, *multipart.FileHeader, error) {
 	return c.Ctx.Request.FormFile(key)
@@ -60,8 +60,11 @@ func (tb *TplBuilder) initTplBuilder() error {
 	if len(tb.TplNameMap) <= 0 {
 		return fmt.Errorf("connt find tpl files")
 	}
+	//不覆盖已经添加的同名函数，如APP添加的url_for
 	for k, fn := range CommonTplFuncs {
-		tb.TplFuncMap[k] = fn
+		if _, ok := tb.TplFuncMap[k]; !ok {
+			tb.TplFuncMap[k] = fn
+		}
 	}
 	tb.isHaveInit = true
 	return nil
@@ -134,7 +137,12 @@ func (tb *TplBuilder) ExecuteTpl(wr io.Writer, name string, data interface{}) er
 		if err != nil {
 			return err
 		}
-		_, err = t.New(tplName).Parse(string(data))
+		//和根模板同名时直接解析到根模板上，用New会得到一个空的根模板
+		tt := t
+		if tplName != name {
+			tt = t.New(tplName)
+		}
+		_, err = tt.Parse(string(data))
 		if err != nil {
 			return err
 		}
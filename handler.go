@@ -1,29 +1,45 @@
 package goweb
 
 import (
+	stdctx "context"
+	"fmt"
 	"github.com/liuyongshuai/thingo/context"
 	"github.com/liuyongshuai/thingo/controller"
 	"github.com/liuyongshuai/thingo/router"
+	"io"
 	"net/http"
 	"reflect"
+	"strings"
 	"sync"
 )
 
 //插件类型
 const (
-	HooksBeforeRun = iota + 1
-	HooksAfterRun
+	HooksBeforeRun   = iota + 1 //控制层执行之前，路由已经匹配好了
+	HooksAfterRun               //控制层执行之后
+	HooksBeforeRoute            //匹配路由之前，可以改写ctx.Request.URL或直接拒绝请求
+	HooksAfterRoute             //匹配路由之后，不管有没有匹配上
+	HooksNotFound               //没有匹配上任何路由时，在执行ErrController之前
+	HooksOnPanic                //发生panic时，panic的值在ctx.PanicValue里，执行完后继续panic
+	HooksBeforeSend             //输出之前，可以修改ctx.Output里的头信息及body
+	HooksAfterSend              //输出之后，ctx.Writer里有最终的状态码及字节数
 )
 
-//插件函数
-type HooksFunc func(ctx *context.ThingoContext)
+//默认的插件优先级
+const DefaultHooksPriority = 0
+
+//插件函数，和路由分组上的插件是同一类型
+type HooksFunc = router.HookFunc
+
+//中间件函数，调用next执行后面的中间件及控制层
+type MiddlewareFunc = router.MiddlewareFunc
 
 //panic后的处理函数
 type RecoverFunc func(*context.ThingoContext)
 
 //控制器注册器
 type ThingoHandler struct {
-	Hooks         map[int][]HooksFunc                  //所有的插件列表
+	Hooks         map[int][]HooksFunc                  //所有的插件列表，按优先级排好序
 	Router        *router.ThingoRouterList             //路由列表
 	RecoverFunc   RecoverFunc                          //panic后的处理函数
 	pool          sync.Pool                            //context上下文池
@@ -34,6 +50,10 @@ type ThingoHandler struct {
 	Port          string                               //监听的端口
 	MaxMemory     int64                                //POST时的最大内存
 	ErrController controller.ThingoControllerInterface //当匹配不上时的错误信息页面
+	Middlewares   []MiddlewareFunc                     //全局的中间件，在所有插件之外执行
+	active        sync.WaitGroup                       //正在处理的请求
+	hookPriority  map[int][]int                        //与Hooks一一对应的优先级
+	Config        *context.ThingoConfig                //所有请求共用的配置
 }
 
 func NewThingoHandler() *ThingoHandler {
@@ -45,11 +65,16 @@ func NewThingoHandler() *ThingoHandler {
 		TplDir:        "./tpl",
 		Router:        router.NewThingoRouterList(),
 		TplCommonData: make(map[interface{}]interface{}),
+		hookPriority:  make(map[int][]int),
+		Config:        context.NewThingoConfig(),
+	}
+	for when := HooksBeforeRun; when <= HooksAfterSend; when++ {
+		cr.Hooks[when] = []HooksFunc{}
 	}
-	cr.Hooks[HooksBeforeRun] = []HooksFunc{}
-	cr.Hooks[HooksAfterRun] = []HooksFunc{}
 	cr.pool.New = func() interface{} {
-		return context.NewThingoContext()
+		ctx := context.NewThingoContext()
+		ctx.Config = cr.Config
+		return ctx
 	}
 	return cr
 }
@@ -59,14 +84,50 @@ func (cr *ThingoHandler) SetPort(port string) {
 	cr.Port = port
 }
 
-//添加一个插件
-func (cr *ThingoHandler) AddHooks(when int, hk HooksFunc) {
+//添加一个插件，when不是已知的插件类型时返回错误
+func (cr *ThingoHandler) AddHooks(when int, hk HooksFunc) error {
+	return cr.AddHooksWithPriority(when, DefaultHooksPriority, hk)
+}
+
+//添加一个带优先级的插件，priority越小越先执行，相同时按添加的顺序
+func (cr *ThingoHandler) AddHooksWithPriority(when int, priority int, hk HooksFunc) error {
 	plist, ok := cr.Hooks[when]
 	if !ok {
-		return
+		return fmt.Errorf("unknown hooks type %d", when)
+	}
+	prios := cr.hookPriority[when]
+	pos := len(plist)
+	for i, p := range prios {
+		if priority < p {
+			pos = i
+			break
+		}
 	}
-	plist = append(plist, hk)
+	plist = append(plist, nil)
+	copy(plist[pos+1:], plist[pos:])
+	plist[pos] = hk
+	prios = append(prios, 0)
+	copy(prios[pos+1:], prios[pos:])
+	prios[pos] = priority
 	cr.Hooks[when] = plist
+	cr.hookPriority[when] = prios
+	return nil
+}
+
+//执行某一类插件，有插件把ctx.Output.Started置为true时返回true
+func (cr *ThingoHandler) runHooks(when int, ctx *context.ThingoContext) bool {
+	for _, hk := range cr.Hooks[when] {
+		hk(ctx)
+		if ctx.Output.Started == true {
+			return true
+		}
+	}
+	return false
+}
+
+//添加全局的中间件
+func (cr *ThingoHandler) Use(mws ...MiddlewareFunc) {
+	cr.Middlewares = append(cr.Middlewares, mws...)
 }
 
 //设置模板路径
@@ -101,6 +162,21 @@ func (cr *ThingoHandler) AddRouters(rs ...*router.ThingoRouterItem) {
 	cr.Router.AddRouters(rs...)
 }
 
+//新建一个路由分组
+func (cr *ThingoHandler) Group(prefix string) *router.ThingoRouterGroup {
+	return cr.Router.Group(prefix)
+}
+
+//设置默认域名
+func (cr *ThingoHandler) SetDefaultHost(host string) {
+	cr.Router.SetDefaultHost(host)
+}
+
+//设置路由缓存
+func (cr *ThingoHandler) SetRouterCache(size int, staticOnly bool) {
+	cr.Router.SetCache(size, staticOnly)
+}
+
 //设置发生错误时的处理函数
 func (cr *ThingoHandler) SetRecoverFunc(fn RecoverFunc) {
 	cr.RecoverFunc = fn
@@ -109,6 +185,50 @@ func (cr *ThingoHandler) SetRecoverFunc(fn RecoverFunc) {
 //设置POST最大内存
 func (cr *ThingoHandler) SetMaxMemory(n int64) {
 	cr.MaxMemory = n
+	cr.Config.MaxMemory = n
+}
+
+//设置信任的代理，可以是CIDR或单个IP，格式有误时返回错误
+func (cr *ThingoHandler) SetTrustedProxies(proxies ...string) error {
+	return cr.Config.SetTrustedProxies(proxies...)
+}
+
+//设置解析请求body时是否不允许有未知的字段
+func (cr *ThingoHandler) SetStrictBody(strict bool) {
+	cr.Config.StrictBody = strict
+}
+
+//设置自动生成ETag的方式，如context.ETagStrong，context.ETagNone为不生成
+func (cr *ThingoHandler) SetETag(mode int) {
+	cr.Config.ETag = mode
+}
+
+//设置默认的缓存策略，为nil时不输出Cache-Control，控制层里可以再用SetCachePolicy修改
+func (cr *ThingoHandler) SetCachePolicy(p *context.CachePolicy) {
+	cr.Config.CachePolicy = p
+}
+
+//设置每个响应默认带的头信息，已有时覆盖
+func (cr *ThingoHandler) SetDefaultHeader(key, val string) {
+	if cr.Config.Headers == nil {
+		cr.Config.Headers = http.Header{}
+	}
+	cr.Config.Headers.Set(key, val)
+}
+
+//给每个响应默认带的头信息再添加一个值，已有时不覆盖
+func (cr *ThingoHandler) AddDefaultHeader(key, val string) {
+	if cr.Config.Headers == nil {
+		cr.Config.Headers = http.Header{}
+	}
+	cr.Config.Headers.Add(key, val)
+}
+
+//去掉默认带的头信息，如“X-Powered-By”、“Server”，缓存相关的头信息用SetCachePolicy修改
+func (cr *ThingoHandler) RemoveDefaultHeader(keys ...string) {
+	for _, k := range keys {
+		cr.Config.Headers.Del(k)
+	}
 }
 
 //设置错误信息提示
@@ -116,34 +236,101 @@ func (cr *ThingoHandler) SetErrController(c controller.ThingoControllerInterface
 	cr.ErrController = c
 }
 
+//等待所有正在处理的请求结束，ctx到期时返回ctx.Err()
+func (cr *ThingoHandler) Wait(ctx stdctx.Context) error {
+	done := make(chan struct{})
+	go func() {
+		cr.active.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 //执行 http.Handler 接口
 func (cr *ThingoHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	cr.active.Add(1)
+	defer cr.active.Done()
+
 	//从池子里提取上下文实例
 	ctx := cr.pool.Get().(*context.ThingoContext)
 	if ctx == nil {
 		panic("get context failed")
 	}
 	ctx.Reset(&rw, r)
-	defer cr.pool.Put(ctx)
+	defer func() {
+		ctx.Release()
+		cr.pool.Put(ctx)
+	}()
 
 	//异常恢复函数设置
 	if cr.RecoverFunc != nil {
 		defer cr.RecoverFunc(ctx)
 	}
 
-	//解析表单提交上来的参数
+	//先执行OnPanic插件，再继续panic给RecoverFunc处理
+	if len(cr.Hooks[HooksOnPanic]) > 0 {
+		defer func() {
+			if err := recover(); err != nil {
+				ctx.PanicValue = err
+				for _, hk := range cr.Hooks[HooksOnPanic] {
+					hk(ctx)
+				}
+				panic(err)
+			}
+		}()
+	}
+
+	//解析表单提交上来的参数，出错时DecodeBody会返回这个错误
 	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		ctx.Input.ParseFormOrMulitForm(cr.MaxMemory)
 	}
 
-	//控制层的类
+	//匹配路由之前的插件，可以改写请求的URL
+	if cr.runHooks(HooksBeforeRoute, ctx) {
+		cr.abort(ctx)
+		return
+	}
+
+	//控制层的类，路由配置的是函数时直接执行函数
 	var controllerIface controller.ThingoControllerInterface
+	var handleFunc router.ThingoHandlerFunc
 	var ok bool
 
 	//开始匹配路由
-	routerItem := cr.Router.Match(ctx, r)
+	routerItem, allowed := cr.Router.Lookup(ctx, ctx.Request)
+	if routerItem != nil {
+		ctx.Output.Produces = routerItem.Produces
+	}
+	if cr.runHooks(HooksAfterRoute, ctx) {
+		cr.abort(ctx)
+		return
+	}
+
+	//路径匹配上了但方法不对，OPTIONS请求直接返回允许的方法
+	if routerItem == nil && len(allowed) > 0 {
+		ctx.Output.AddHeader("Allow", strings.Join(allowed, ", "))
+		if r.Method == http.MethodOptions {
+			ctx.Output.SetStatus(http.StatusNoContent)
+			cr.send(ctx)
+			return
+		}
+	}
+
 	if routerItem == nil {
-		ctx.Output.SetStatus(http.StatusNotFound)
+		if len(allowed) > 0 {
+			ctx.Output.SetStatus(http.StatusMethodNotAllowed)
+		} else {
+			ctx.Output.SetStatus(http.StatusNotFound)
+			if cr.runHooks(HooksNotFound, ctx) {
+				cr.abort(ctx)
+				return
+			}
+		}
 		reflectVal := reflect.ValueOf(cr.ErrController)
 		ct := reflect.Indirect(reflectVal).Type()
 		vc := reflect.New(ct)
@@ -151,7 +338,7 @@ func (cr *ThingoHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		if !ok {
 			panic("invalid controller")
 		}
-	} else {
+	} else if handleFunc = routerItem.HandleFunc(); handleFunc == nil {
 		//实例化一个控制层对象
 		vc := reflect.New(routerItem.ControllerType)
 		controllerIface, ok = vc.Interface().(controller.ThingoControllerInterface)
@@ -160,26 +347,67 @@ func (cr *ThingoHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	//执行Before插件
-	for _, hk := range cr.Hooks[HooksBeforeRun] {
-		hk(ctx)
+	//依次执行：全局中间件、全局插件、路由分组的插件及中间件、路由的中间件，最后是控制层
+	mws := append([]MiddlewareFunc{}, cr.Middlewares...)
+	mws = append(mws, router.HooksMiddleware(cr.Hooks[HooksBeforeRun], cr.Hooks[HooksAfterRun]))
+	if routerItem != nil {
+		if routerItem.Group != nil {
+			mws = append(mws, routerItem.Group.Middlewares()...)
+		}
+		mws = append(mws, routerItem.Middlewares...)
 	}
+	inited, ran := false, false
+	router.RunMiddlewares(ctx, mws, func() {
+		ran = true
+		if handleFunc != nil {
+			handleFunc(ctx)
+			return
+		}
+		controllerIface.Init(ctx, controllerIface, cr.Tpl, cr.TplCommonData)
+		inited = true
+		err := controllerIface.Prepare()
+		if err == nil {
+			controllerIface.Run()
+		}
+	})
 
-	if ctx.Output.Started == true {
+	//被中间件中断时控制层没有初始化，也就不用清理
+	if inited {
+		controllerIface.Finish()
+	}
+	//BeforeRun、分组的Before插件或中间件中断了请求
+	if !ran {
+		cr.abort(ctx)
 		return
 	}
+	//刷新输出
+	cr.send(ctx)
+}
 
-	//执行控制层
-	controllerIface.Init(ctx, controllerIface, cr.Tpl, cr.TplCommonData)
-	err := controllerIface.Prepare()
-	if err == nil {
-		controllerIface.Run()
+/**
+插件、中间件中断请求后照常走输出流程，BeforeSend、AfterSend插件同样执行
+只把Started置为true、还没写出任何内容时，暂存的状态码、头信息、cookie及body由Send输出
+*/
+func (cr *ThingoHandler) abort(ctx *context.ThingoContext) {
+	if !ctx.Writer.Written() && !ctx.Output.Streaming {
+		ctx.Output.Started = false
 	}
-	//执行After插件
-	for _, hk := range cr.Hooks[HooksAfterRun] {
+	cr.send(ctx)
+}
+
+//输出响应，前后分别执行BeforeSend、AfterSend插件
+func (cr *ThingoHandler) send(ctx *context.ThingoContext) {
+	//先停掉SSE的心跳，避免在AfterSend或收尾时还在写入
+	ctx.CloseSSE()
+	for _, hk := range cr.Hooks[HooksBeforeSend] {
 		hk(ctx)
 	}
-	controllerIface.Finish()
-	//刷新输出
 	ctx.Output.Send()
+	//被中间件包装过的ResponseWriter（如压缩）要在body都写完后收尾，AfterSend里才能看到最终的状态码及字节数
+	if c, ok := ctx.ResponseWriter.(io.Closer); ok {
+		c.Close()
+	}
+	for _, hk := range cr.Hooks[HooksAfterSend] {
+		hk(ctx)
+	}
 }
@@ -0,0 +1,425 @@
+package goweb
+
+import (
+	"github.com/liuyongshuai/thingo/context"
+	"github.com/liuyongshuai/thingo/controller"
+	"github.com/liuyongshuai/thingo/router"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testErrController struct {
+	controller.ThingoController
+}
+
+func (c *testErrController) Run() {
+	c.Ctx.Output.SetBody([]byte("not found"))
+}
+
+//新建一个处理器，添加路由/ok，处理时记录到trace里
+func newTestHandler(trace *[]string) *ThingoHandler {
+	cr := NewThingoHandler()
+	cr.SetErrController(&testErrController{})
+	cr.AddRouter(&router.ThingoRouterItem{
+		Type:   router.RouterTypePathInfo,
+		Config: "/ok",
+		Handler: router.ThingoHandlerFunc(func(ctx *context.ThingoContext) {
+			*trace = append(*trace, "handler")
+		}),
+	})
+	return cr
+}
+
+//记录插件执行顺序的插件
+func traceHook(trace *[]string, name string) HooksFunc {
+	return func(*context.ThingoContext) {
+		*trace = append(*trace, name)
+	}
+}
+
+func TestHooksOrder(t *testing.T) {
+	var trace []string
+	cr := newTestHandler(&trace)
+	names := map[int]string{
+		HooksBeforeRoute: "BeforeRoute",
+		HooksAfterRoute:  "AfterRoute",
+		HooksNotFound:    "NotFound",
+		HooksBeforeRun:   "BeforeRun",
+		HooksAfterRun:    "AfterRun",
+		HooksBeforeSend:  "BeforeSend",
+		HooksAfterSend:   "AfterSend",
+	}
+	for when, name := range names {
+		cr.AddHooks(when, traceHook(&trace, name))
+	}
+	cr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ok", nil))
+	want := []string{"BeforeRoute", "AfterRoute", "BeforeRun", "handler", "AfterRun", "BeforeSend", "AfterSend"}
+	if !reflect.DeepEqual(trace, want) {
+		t.Errorf("matched: trace = %v, want %v", trace, want)
+	}
+
+	trace = nil
+	rec := httptest.NewRecorder()
+	cr.ServeHTTP(rec, httptest.NewRequest("GET", "/missing", nil))
+	want = []string{"BeforeRoute", "AfterRoute", "NotFound", "BeforeRun", "AfterRun", "BeforeSend", "AfterSend"}
+	if !reflect.DeepEqual(trace, want) {
+		t.Errorf("not found: trace = %v, want %v", trace, want)
+	}
+	if rec.Code != http.StatusNotFound || rec.Body.String() != "not found" {
+		t.Errorf("not found: got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+//priority越小越先执行，相同时按添加的顺序
+func TestHooksPriority(t *testing.T) {
+	var trace []string
+	cr := newTestHandler(&trace)
+	cr.AddHooksWithPriority(HooksBeforeRun, 10, traceHook(&trace, "p10-a"))
+	cr.AddHooks(HooksBeforeRun, traceHook(&trace, "p0-a"))
+	cr.AddHooksWithPriority(HooksBeforeRun, -5, traceHook(&trace, "p-5"))
+	cr.AddHooksWithPriority(HooksBeforeRun, 10, traceHook(&trace, "p10-b"))
+	cr.AddHooksWithPriority(HooksBeforeRun, DefaultHooksPriority, traceHook(&trace, "p0-b"))
+	cr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ok", nil))
+	want := []string{"p-5", "p0-a", "p0-b", "p10-a", "p10-b", "handler"}
+	if !reflect.DeepEqual(trace, want) {
+		t.Errorf("trace = %v, want %v", trace, want)
+	}
+	if err := cr.AddHooks(HooksAfterSend+1, traceHook(&trace, "x")); err == nil {
+		t.Error("unknown hooks type should fail")
+	}
+}
+
+//记录执行顺序的中间件
+func traceMiddleware(trace *[]string, name string) MiddlewareFunc {
+	return func(ctx *context.ThingoContext, next func()) {
+		*trace = append(*trace, name+" in")
+		next()
+		*trace = append(*trace, name+" out")
+	}
+}
+
+//依次执行全局中间件、BeforeRun插件、分组的中间件、路由的中间件，再执行控制层
+func TestMiddlewareChain(t *testing.T) {
+	var trace []string
+	cr := newTestHandler(&trace)
+	cr.Use(traceMiddleware(&trace, "global1"), traceMiddleware(&trace, "global2"))
+	cr.AddHooks(HooksBeforeRun, traceHook(&trace, "BeforeRun"))
+	cr.AddHooks(HooksAfterRun, traceHook(&trace, "AfterRun"))
+	cr.Group("g").Use(traceMiddleware(&trace, "group")).AddRouter(&router.ThingoRouterItem{
+		Type:        router.RouterTypePathInfo,
+		Config:      "ok",
+		Middlewares: []MiddlewareFunc{traceMiddleware(&trace, "route")},
+		Handler: router.ThingoHandlerFunc(func(ctx *context.ThingoContext) {
+			trace = append(trace, "handler")
+		}),
+	})
+	cr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/g/ok", nil))
+	want := []string{
+		"global1 in", "global2 in", "BeforeRun", "group in", "route in",
+		"handler",
+		"route out", "group out", "AfterRun", "global2 out", "global1 out",
+	}
+	if !reflect.DeepEqual(trace, want) {
+		t.Errorf("trace = %v\nwant %v", trace, want)
+	}
+}
+
+//中间件不调用next时中断请求，它设置的内容照常输出；重复调用next不会重复执行控制层
+func TestMiddlewareShortCircuit(t *testing.T) {
+	var trace []string
+	cr := newTestHandler(&trace)
+	cr.Use(func(ctx *context.ThingoContext, next func()) {
+		if ctx.Input.Query("token") == "" {
+			ctx.Output.SetStatus(http.StatusUnauthorized)
+			ctx.Output.SetBody([]byte("login required"))
+			return
+		}
+		next()
+		next()
+	})
+	cr.AddHooks(HooksAfterRun, traceHook(&trace, "AfterRun"))
+	cr.AddHooks(HooksAfterSend, traceHook(&trace, "AfterSend"))
+	rec := httptest.NewRecorder()
+	cr.ServeHTTP(rec, httptest.NewRequest("GET", "/ok", nil))
+	if rec.Code != http.StatusUnauthorized || rec.Body.String() != "login required" {
+		t.Errorf("got %d %q", rec.Code, rec.Body.String())
+	}
+	if want := []string{"AfterSend"}; !reflect.DeepEqual(trace, want) {
+		t.Errorf("trace = %v, want %v", trace, want)
+	}
+
+	trace = nil
+	rec = httptest.NewRecorder()
+	cr.ServeHTTP(rec, httptest.NewRequest("GET", "/ok?token=x", nil))
+	if want := []string{"handler", "AfterRun", "AfterSend"}; !reflect.DeepEqual(trace, want) {
+		t.Errorf("trace = %v, want %v", trace, want)
+	}
+}
+
+//插件中断请求时也要执行BeforeSend、AfterSend，暂存的状态码、头信息、cookie及body照常输出
+func TestHooksAbort(t *testing.T) {
+	//添加插件，返回要请求的路径
+	addHooks := func(when int) func(cr *ThingoHandler, trace *[]string, hks ...HooksFunc) string {
+		return func(cr *ThingoHandler, trace *[]string, hks ...HooksFunc) string {
+			for _, hk := range hks {
+				cr.AddHooks(when, hk)
+			}
+			if when == HooksNotFound {
+				return "/missing"
+			}
+			return "/ok"
+		}
+	}
+	tests := []struct {
+		name  string
+		setup func(cr *ThingoHandler, trace *[]string, hks ...HooksFunc) string
+	}{
+		{"BeforeRoute", addHooks(HooksBeforeRoute)},
+		{"AfterRoute", addHooks(HooksAfterRoute)},
+		{"NotFound", addHooks(HooksNotFound)},
+		{"BeforeRun", addHooks(HooksBeforeRun)},
+		{"group Before", func(cr *ThingoHandler, trace *[]string, hks ...HooksFunc) string {
+			cr.Group("g").Before(hks...).AddRouter(&router.ThingoRouterItem{
+				Type:   router.RouterTypePathInfo,
+				Config: "ok",
+				Handler: router.ThingoHandlerFunc(func(ctx *context.ThingoContext) {
+					*trace = append(*trace, "handler")
+				}),
+			})
+			return "/g/ok"
+		}},
+	}
+	for _, tt := range tests {
+		var trace []string
+		cr := newTestHandler(&trace)
+		path := tt.setup(cr, &trace, func(ctx *context.ThingoContext) {
+			ctx.Output.SetStatus(http.StatusForbidden)
+			ctx.Output.AddHeader("X-Reason", "denied")
+			ctx.Output.AddCookie("sid", "abc")
+			ctx.Output.SetBody([]byte("forbidden"))
+			ctx.Output.Started = true
+		}, traceHook(&trace, "next hook"))
+		cr.AddHooks(HooksBeforeSend, traceHook(&trace, "BeforeSend"))
+		status := 0
+		cr.AddHooks(HooksAfterSend, func(ctx *context.ThingoContext) {
+			status = ctx.Writer.Status()
+			trace = append(trace, "AfterSend")
+		})
+		rec := httptest.NewRecorder()
+		cr.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if want := []string{"BeforeSend", "AfterSend"}; !reflect.DeepEqual(trace, want) {
+			t.Errorf("%s: trace = %v, want %v", tt.name, trace, want)
+		}
+		if rec.Code != http.StatusForbidden || rec.Body.String() != "forbidden" || status != http.StatusForbidden {
+			t.Errorf("%s: got %d %q, AfterSend saw %d", tt.name, rec.Code, rec.Body.String(), status)
+		}
+		if rec.Header().Get("X-Reason") != "denied" || len(rec.Result().Cookies()) != 1 {
+			t.Errorf("%s: headers = %v", tt.name, rec.Header())
+		}
+	}
+}
+
+//插件自己已经写出了内容时不再重复输出
+func TestHooksAbortWritten(t *testing.T) {
+	var trace []string
+	cr := newTestHandler(&trace)
+	cr.AddHooks(HooksBeforeRoute, func(ctx *context.ThingoContext) {
+		ctx.Output.SetStatus(http.StatusTeapot)
+		ctx.Output.Write([]byte("tea"))
+	})
+	cr.AddHooks(HooksAfterSend, traceHook(&trace, "AfterSend"))
+	rec := httptest.NewRecorder()
+	cr.ServeHTTP(rec, httptest.NewRequest("GET", "/ok", nil))
+	if rec.Code != http.StatusTeapot || rec.Body.String() != "tea" || len(trace) != 1 {
+		t.Errorf("got %d %q, trace = %v", rec.Code, rec.Body.String(), trace)
+	}
+}
+
+//没有设置状态码时，输出完后Status()为默认的200
+func TestWriterStatusImplicit(t *testing.T) {
+	var trace []string
+	cr := newTestHandler(&trace)
+	statuses := map[string]int{}
+	cr.AddHooks(HooksBeforeSend, func(ctx *context.ThingoContext) {
+		statuses["BeforeSend"] = ctx.Writer.Status()
+	})
+	cr.AddHooks(HooksAfterSend, func(ctx *context.ThingoContext) {
+		statuses["AfterSend"] = ctx.Writer.Status()
+	})
+	cr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ok", nil))
+	if want := map[string]int{"BeforeSend": 0, "AfterSend": http.StatusOK}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("statuses = %v, want %v", statuses, want)
+	}
+}
+
+//OnPanic插件能拿到panic的值，之后继续交给RecoverFunc
+func TestHooksOnPanic(t *testing.T) {
+	cr := NewThingoHandler()
+	cr.AddRouter(&router.ThingoRouterItem{
+		Type:    router.RouterTypePathInfo,
+		Config:  "/panic",
+		Handler: router.ThingoHandlerFunc(func(*context.ThingoContext) { panic("boom") }),
+	})
+	var seen, recovered interface{}
+	cr.AddHooks(HooksOnPanic, func(ctx *context.ThingoContext) { seen = ctx.PanicValue })
+	cr.SetRecoverFunc(func(ctx *context.ThingoContext) { recovered = recover() })
+	cr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+	if seen != "boom" || recovered != "boom" {
+		t.Errorf("OnPanic saw %v, RecoverFunc recovered %v", seen, recovered)
+	}
+}
+
+//SSE的心跳在输出之前停止，AfterSend及之后不会再写入
+func TestSSEClosedBeforeSend(t *testing.T) {
+	cr := NewThingoHandler()
+	cr.AddRouter(&router.ThingoRouterItem{
+		Type:   router.RouterTypePathInfo,
+		Config: "/events",
+		Handler: router.ThingoHandlerFunc(func(ctx *context.ThingoContext) {
+			sse := ctx.SSE()
+			sse.Heartbeat(time.Millisecond)
+			sse.SendData("hi")
+			time.Sleep(5 * time.Millisecond)
+		}),
+	})
+	var before, after int64
+	cr.AddHooks(HooksAfterSend, func(ctx *context.ThingoContext) {
+		before = ctx.Writer.Size()
+		time.Sleep(5 * time.Millisecond)
+		after = ctx.Writer.Size()
+	})
+	srv := httptest.NewServer(cr)
+	defer srv.Close()
+	for i := 0; i < 5; i++ {
+		resp, err := http.Get(srv.URL + "/events")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if before == 0 || before != after {
+			t.Errorf("written %d bytes before AfterSend and %d after", before, after)
+		}
+	}
+}
+
+//默认头信息可以增删改，控制层里的修改只影响本次响应
+func TestDefaultHeaders(t *testing.T) {
+	var trace []string
+	cr := newTestHandler(&trace)
+	rec := httptest.NewRecorder()
+	cr.ServeHTTP(rec, httptest.NewRequest("GET", "/ok", nil))
+	for k, v := range map[string]string{
+		"X-Powered-By":    "ThingoGo",
+		"Server":          "ThingoGo",
+		"Referrer-Policy": "origin-when-cross-origin",
+		"Cache-Control":   "no-cache, must-revalidate",
+		"Expires":         "0",
+	} {
+		if got := rec.Header().Get(k); got != v {
+			t.Errorf("default %s = %q, want %q", k, got, v)
+		}
+	}
+
+	cr.RemoveDefaultHeader("X-Powered-By", "Server")
+	cr.SetDefaultHeader("Referrer-Policy", "no-referrer")
+	cr.AddDefaultHeader("Vary", "Origin")
+	cr.AddDefaultHeader("Vary", "Cookie")
+	cr.SetCachePolicy(nil)
+	cr.AddRouter(&router.ThingoRouterItem{
+		Type:   router.RouterTypePathInfo,
+		Config: "/modify",
+		Handler: router.ThingoHandlerFunc(func(ctx *context.ThingoContext) {
+			ctx.ResponseWriter.Header().Add("Vary", "Accept")
+			ctx.Output.AddHeader("Referrer-Policy", "same-origin")
+		}),
+	})
+	for _, c := range []struct {
+		path     string
+		referrer string
+		vary     []string
+	}{
+		{"/modify", "same-origin", []string{"Origin", "Cookie", "Accept"}},
+		{"/ok", "no-referrer", []string{"Origin", "Cookie"}},
+	} {
+		rec = httptest.NewRecorder()
+		cr.ServeHTTP(rec, httptest.NewRequest("GET", c.path, nil))
+		h := rec.Header()
+		for _, k := range []string{"X-Powered-By", "Server", "Cache-Control", "Expires"} {
+			if got := h.Get(k); got != "" {
+				t.Errorf("%s: %s = %q after removing", c.path, k, got)
+			}
+		}
+		if got := h.Get("Referrer-Policy"); got != c.referrer {
+			t.Errorf("%s: Referrer-Policy = %q, want %q", c.path, got, c.referrer)
+		}
+		if got := h.Values("Vary"); !reflect.DeepEqual(got, c.vary) {
+			t.Errorf("%s: Vary = %q, want %q", c.path, got, c.vary)
+		}
+	}
+
+	//每个处理器有自己的一份默认头信息
+	rec = httptest.NewRecorder()
+	newTestHandler(&trace).ServeHTTP(rec, httptest.NewRequest("GET", "/ok", nil))
+	if got := rec.Header().Get("Server"); got != "ThingoGo" {
+		t.Errorf("new handler Server = %q", got)
+	}
+}
+
+//按请求方法分发：OPTIONS返回允许的方法，HEAD走GET的路由但没有body，方法不对时由ErrController输出405
+func TestMethodDispatch(t *testing.T) {
+	var trace []string
+	cr := NewThingoHandler()
+	cr.SetErrController(&testErrController{})
+	for _, m := range []string{http.MethodGet, http.MethodPost} {
+		m := m
+		cr.AddRouter(&router.ThingoRouterItem{
+			Type:    router.RouterTypePathInfo,
+			Config:  "/item",
+			Methods: []string{m},
+			Handler: router.ThingoHandlerFunc(func(ctx *context.ThingoContext) {
+				trace = append(trace, m)
+				ctx.Output.SetBody([]byte("item"))
+			}),
+		})
+	}
+	srv := httptest.NewServer(cr)
+	defer srv.Close()
+
+	for _, c := range []struct {
+		method string
+		status int
+		allow  string
+		body   string
+		trace  []string
+	}{
+		{http.MethodGet, http.StatusOK, "", "item", []string{"GET"}},
+		{http.MethodPost, http.StatusOK, "", "item", []string{"POST"}},
+		{http.MethodHead, http.StatusOK, "", "", []string{"GET"}},
+		{http.MethodOptions, http.StatusNoContent, "GET, HEAD, OPTIONS, POST", "", nil},
+		{http.MethodDelete, http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS, POST", "not found", nil},
+	} {
+		trace = nil
+		req, _ := http.NewRequest(c.method, srv.URL+"/item", nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", c.method, err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != c.status || resp.Header.Get("Allow") != c.allow || string(body) != c.body {
+			t.Errorf("%s: got %d Allow=%q body=%q, want %d Allow=%q body=%q",
+				c.method, resp.StatusCode, resp.Header.Get("Allow"), body, c.status, c.allow, c.body)
+		}
+		if !reflect.DeepEqual(trace, c.trace) {
+			t.Errorf("%s: trace = %v, want %v", c.method, trace, c.trace)
+		}
+		if c.method == http.MethodHead && resp.ContentLength != int64(len("item")) {
+			t.Errorf("HEAD: Content-Length = %d", resp.ContentLength)
+		}
+	}
+}
@@ -0,0 +1,413 @@
+/**
+ * @author      Liu Yongshuai<liuyongshuai@hotmail.com>
+ * @package     middleware
+ * @date        2026-10-17 21:05
+ */
+package middleware
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"github.com/liuyongshuai/thingo/context"
+	"github.com/liuyongshuai/thingo/router"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//默认的压缩阈值，body小于它时不压缩
+const DefaultCompressMinSize = 1024
+
+//默认可以压缩的类型，以“+json”、“+xml”结尾的类型也会压缩
+var DefaultCompressTypes = []string{
+	"text/html",
+	"text/plain",
+	"text/css",
+	"text/csv",
+	"text/xml",
+	"text/javascript",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/yaml",
+	"application/x-yaml",
+	"image/svg+xml",
+	"application/wasm",
+}
+
+//压缩器，Flush要把已写入的数据都发出去，流式输出时用
+type Compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+//创建压缩器的函数，level为压缩级别，各算法的取值范围不一样
+type CodecFunc func(w io.Writer, level int) (Compressor, error)
+
+var (
+	codecLock  sync.RWMutex
+	codecs     = map[string]CodecFunc{}
+	codecNames []string //按注册顺序，客户端给的权重相同时优先用前面的
+)
+
+func init() {
+	RegisterCodec("gzip", newGzipCompressor)
+	RegisterCodec("deflate", newDeflateCompressor)
+}
+
+/**
+注册一种压缩算法，name为Accept-Encoding里的名称，如接入brotli：
+	middleware.RegisterCodec("br", func(w io.Writer, level int) (middleware.Compressor, error) {
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression), nil
+	})
+
+已有同名的则替换，需在开始运行前调用
+*/
+func RegisterCodec(name string, fn CodecFunc) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || fn == nil {
+		return
+	}
+	codecLock.Lock()
+	defer codecLock.Unlock()
+	if _, ok := codecs[name]; !ok {
+		codecNames = append(codecNames, name)
+	}
+	codecs[name] = fn
+}
+
+//提取注册的压缩算法
+func lookupCodec(name string) CodecFunc {
+	codecLock.RLock()
+	defer codecLock.RUnlock()
+	return codecs[name]
+}
+
+//已注册的压缩算法名称
+func CodecNames() []string {
+	codecLock.RLock()
+	defer codecLock.RUnlock()
+	return append([]string{}, codecNames...)
+}
+
+//gzip的压缩器按级别放在池子里复用，每个都要占不少内存
+var gzipPools sync.Map
+
+type gzipCompressor struct {
+	*gzip.Writer
+	pool *sync.Pool
+}
+
+//关闭后放回池子里
+func (c *gzipCompressor) Close() error {
+	err := c.Writer.Close()
+	c.pool.Put(c.Writer)
+	return err
+}
+
+func newGzipCompressor(w io.Writer, level int) (Compressor, error) {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		return nil, errors.New("gzip: invalid compression level " + strconv.Itoa(level))
+	}
+	p, _ := gzipPools.LoadOrStore(level, &sync.Pool{New: func() interface{} {
+		gz, _ := gzip.NewWriterLevel(nil, level)
+		return gz
+	}})
+	pool := p.(*sync.Pool)
+	gz := pool.Get().(*gzip.Writer)
+	gz.Reset(w)
+	return &gzipCompressor{Writer: gz, pool: pool}, nil
+}
+
+func newDeflateCompressor(w io.Writer, level int) (Compressor, error) {
+	return flate.NewWriter(w, level)
+}
+
+//压缩的配置
+type CompressOptions struct {
+	Level    int      //压缩级别，默认为-1即各算法的默认级别
+	MinSize  int      //body小于它时不压缩，默认DefaultCompressMinSize，负数表示都压缩
+	Types    []string //可以压缩的类型，默认DefaultCompressTypes
+	Encoders []string //可以用的压缩算法，按优先顺序，默认为所有注册的
+}
+
+/**
+按请求的Accept-Encoding压缩响应，如：
+	app.Use(middleware.Compress(nil))
+
+在body达到MinSize或转为流式输出时才决定是否压缩，压缩时会：
+
+	设置Content-Encoding、去掉Content-Length、强ETag转为弱ETag
+	可以压缩的类型都会加上“Vary: Accept-Encoding”
+
+HEAD请求、206/204/304等没有完整body的响应、已经有Content-Encoding的响应不压缩
+Flush时会先刷新压缩器，流式输出、SSE都可以用，WebSocket接管连接后不受影响
+*/
+func Compress(opts *CompressOptions) router.MiddlewareFunc {
+	cfg := CompressOptions{Level: -1, MinSize: DefaultCompressMinSize, Types: DefaultCompressTypes}
+	if opts != nil {
+		cfg.Level = opts.Level
+		if opts.MinSize != 0 {
+			cfg.MinSize = opts.MinSize
+		}
+		if len(opts.Types) > 0 {
+			cfg.Types = opts.Types
+		}
+		cfg.Encoders = opts.Encoders
+	}
+	types := make([]string, 0, len(cfg.Types))
+	for _, t := range cfg.Types {
+		types = append(types, strings.ToLower(strings.TrimSpace(t)))
+	}
+	cfg.Types = types
+
+	return func(ctx *context.ThingoContext, next func()) {
+		if ctx.Request.Method == http.MethodHead {
+			next()
+			return
+		}
+		cw := &compressWriter{
+			ResponseWriter: ctx.ResponseWriter,
+			opts:           &cfg,
+			accept:         ctx.Request.Header.Get("Accept-Encoding"),
+		}
+		ctx.ResponseWriter = cw
+		//Output在中间件执行完后才发出，由ThingoHandler在发出后调用Close，没有发出时请求结束时再关闭
+		ctx.OnRelease(func() { cw.Close() })
+		next()
+	}
+}
+
+//压缩用的http.ResponseWriter，决定好是否压缩前先把数据暂存起来
+type compressWriter struct {
+	http.ResponseWriter
+	opts    *CompressOptions
+	accept  string     //请求的Accept-Encoding
+	status  int        //暂存的状态码
+	buf     []byte     //决定是否压缩前暂存的body
+	decided bool       //是否已经决定好了
+	comp    Compressor //压缩器，不压缩时为nil
+	hijack  bool       //连接是否已被接管
+	closed  bool       //是否已关闭
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.decided || w.status > 0 {
+		return
+	}
+	w.status = status
+	//没有body的响应直接放过去
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		w.start(false)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if w.opts.MinSize >= 0 && len(w.buf) < w.opts.MinSize && !w.sizeKnown() {
+			return len(p), nil
+		}
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if w.comp != nil {
+		return w.comp.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+//头信息里的Content-Length达到了阈值，不用再等
+func (w *compressWriter) sizeKnown() bool {
+	n, err := strconv.Atoi(w.Header().Get("Content-Length"))
+	return err == nil && n >= w.opts.MinSize
+}
+
+//流式输出时不等body达到阈值，直接决定
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.start(true)
+	}
+	if w.comp != nil {
+		w.comp.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+//WebSocket等接管连接后就不再压缩了
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("http.Hijacker not supported")
+	}
+	conn, brw, err := h.Hijack()
+	if err == nil {
+		w.hijack = true
+		w.decided = true
+		w.buf = nil
+	}
+	return conn, brw, err
+}
+
+func (w *compressWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	return nil
+}
+
+//返回被包装的对象，给http.ResponseController用
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+//决定是否压缩，然后发出头信息及暂存的body，canCompress为false时不压缩
+func (w *compressWriter) start(canCompress bool) error {
+	w.decided = true
+	header := w.Header()
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if len(w.buf) > 0 && header.Get("Content-Type") == "" {
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	compressible := canCompress && header.Get("Content-Encoding") == "" && w.matchType(header.Get("Content-Type"))
+	if compressible {
+		addVary(header, "Accept-Encoding")
+	}
+	if compressible && status != http.StatusPartialContent && header.Get("Content-Range") == "" {
+		if name := w.negotiate(); name != "" {
+			comp, err := lookupCodec(name)(w.ResponseWriter, w.opts.Level)
+			if err == nil {
+				w.comp = comp
+				header.Set("Content-Encoding", name)
+				header.Del("Content-Length")
+				if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+					header.Set("ETag", "W/"+etag)
+				}
+			}
+		}
+	}
+
+	if w.status > 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.comp != nil {
+		_, err = w.comp.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+//是否为可以压缩的类型
+func (w *compressWriter) matchType(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	if strings.HasSuffix(mt, "+json") || strings.HasSuffix(mt, "+xml") {
+		return true
+	}
+	for _, t := range w.opts.Types {
+		if t == mt || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mt, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+//按Accept-Encoding里的权重选择压缩算法，权重相同时按配置的顺序，都不能用时返回空
+func (w *compressWriter) negotiate() string {
+	if w.accept == "" {
+		return ""
+	}
+	names := w.opts.Encoders
+	if len(names) == 0 {
+		names = CodecNames()
+	}
+	quality := make(map[string]float64)
+	for _, part := range strings.Split(w.accept, ",") {
+		name := part
+		q := 1.0
+		if i := strings.Index(part, ";"); i >= 0 {
+			name = part[:i]
+			param := strings.TrimSpace(part[i+1:])
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "" {
+			quality[name] = q
+		}
+	}
+	best, bestQ := "", 0.0
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if lookupCodec(name) == nil {
+			continue
+		}
+		q, ok := quality[name]
+		if !ok {
+			q = quality["*"]
+		}
+		if q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+//body都写完后把暂存的数据发出去，关闭压缩器，重复调用时不再处理
+func (w *compressWriter) Close() error {
+	if w.closed || w.hijack {
+		return nil
+	}
+	w.closed = true
+	var err error
+	if !w.decided {
+		//body没达到阈值
+		err = w.start(false)
+	}
+	if w.comp != nil {
+		if e := w.comp.Close(); err == nil {
+			err = e
+		}
+		w.comp = nil
+	}
+	return err
+}
+
+//往Vary头信息里添加一个字段，已有时不重复添加
+func addVary(header http.Header, field string) {
+	for _, v := range header.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			f = strings.TrimSpace(f)
+			if f == "*" || strings.EqualFold(f, field) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", field)
+}
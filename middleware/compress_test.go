@@ -0,0 +1,373 @@
+/**
+ * @author      Liu Yongshuai<liuyongshuai@hotmail.com>
+ * @package     middleware
+ * @date        2026-10-18 16:30
+ */
+package middleware
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	goweb "github.com/liuyongshuai/thingo"
+	"github.com/liuyongshuai/thingo/context"
+	"github.com/liuyongshuai/thingo/router"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//超过默认阈值的文本
+var compressBody = strings.Repeat("hello thingo, ", 100)
+
+//新建一个带压缩中间件的处理器，路由“/”由fn处理
+func newCompressHandler(opts *CompressOptions, fn func(ctx *context.ThingoContext)) *goweb.ThingoHandler {
+	cr := goweb.NewThingoHandler()
+	cr.Use(Compress(opts))
+	cr.AddRouter(&router.ThingoRouterItem{
+		Type:    router.RouterTypePathInfo,
+		Config:  "/",
+		Handler: router.ThingoHandlerFunc(fn),
+	})
+	return cr
+}
+
+//按Accept-Encoding请求一次
+func compressServe(opts *CompressOptions, accept string, fn func(ctx *context.ThingoContext)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if accept != "" {
+		req.Header.Set("Accept-Encoding", accept)
+	}
+	rec := httptest.NewRecorder()
+	newCompressHandler(opts, fn).ServeHTTP(rec, req)
+	return rec
+}
+
+//输出指定类型的body
+func textHandler(contentType, body string) func(ctx *context.ThingoContext) {
+	return func(ctx *context.ThingoContext) {
+		if contentType != "" {
+			ctx.Output.AddHeader("Content-Type", contentType)
+		}
+		ctx.Output.SetBody([]byte(body))
+	}
+}
+
+//按Content-Encoding解压
+func decodeBody(t *testing.T, header http.Header, r io.Reader) string {
+	t.Helper()
+	var err error
+	switch header.Get("Content-Encoding") {
+	case "gzip":
+		if r, err = gzip.NewReader(r); err != nil {
+			t.Fatalf("gzip: %v", err)
+		}
+	case "deflate":
+		r = flate.NewReader(r)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	return string(b)
+}
+
+func TestCompressNegotiate(t *testing.T) {
+	tests := []struct {
+		accept   string
+		encoders []string
+		want     string
+	}{
+		{"", nil, ""},
+		{"gzip", nil, "gzip"},
+		{"GZIP", nil, "gzip"},
+		{"deflate", nil, "deflate"},
+		{"gzip, deflate", nil, "gzip"},
+		{"deflate, gzip", nil, "gzip"},
+		{"gzip;q=0.5, deflate", nil, "deflate"},
+		{"gzip; q=0.8, deflate; q=0.9", nil, "deflate"},
+		//q=0表示不接受
+		{"gzip;q=0", nil, ""},
+		{"gzip;q=0, deflate;q=0.1", nil, "deflate"},
+		{"identity", nil, ""},
+		{"identity, br", nil, ""},
+		//*匹配没有单独列出的算法
+		{"*", nil, "gzip"},
+		{"*;q=0", nil, ""},
+		{"gzip;q=0, *;q=0.2", nil, "deflate"},
+		{"*, gzip;q=0.1", nil, "deflate"},
+		//只用配置里的算法
+		{"gzip, deflate", []string{"deflate"}, "deflate"},
+		{"gzip", []string{"deflate"}, ""},
+	}
+	for _, tt := range tests {
+		rec := compressServe(&CompressOptions{Encoders: tt.encoders}, tt.accept, textHandler("text/plain", compressBody))
+		got := rec.Header().Get("Content-Encoding")
+		if got != tt.want {
+			t.Errorf("Accept-Encoding %q encoders %v: got %q, want %q", tt.accept, tt.encoders, got, tt.want)
+			continue
+		}
+		if body := decodeBody(t, rec.Header(), rec.Body); body != compressBody {
+			t.Errorf("Accept-Encoding %q: body mismatch, got %d bytes", tt.accept, len(body))
+		}
+		//可以压缩的类型不管最后压没压都要加Vary
+		if v := rec.Header().Get("Vary"); v != "Accept-Encoding" {
+			t.Errorf("Accept-Encoding %q: Vary = %q", tt.accept, v)
+		}
+	}
+}
+
+func TestCompressMinSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		minSize int
+		body    string
+		length  string //控制层设置的Content-Length
+		want    string
+	}{
+		{"below default", 0, strings.Repeat("a", DefaultCompressMinSize-1), "", ""},
+		{"at default", 0, strings.Repeat("a", DefaultCompressMinSize), "", "gzip"},
+		{"custom", 50, strings.Repeat("a", 60), "", "gzip"},
+		{"below custom", 50, strings.Repeat("a", 40), "", ""},
+		{"always", -1, "a", "", "gzip"},
+		{"empty", -1, "", "", ""},
+	}
+	for _, tt := range tests {
+		rec := compressServe(&CompressOptions{MinSize: tt.minSize}, "gzip", textHandler("text/plain", tt.body))
+		if got := rec.Header().Get("Content-Encoding"); got != tt.want {
+			t.Errorf("%s: Content-Encoding = %q, want %q", tt.name, got, tt.want)
+		}
+		if body := decodeBody(t, rec.Header(), rec.Body); body != tt.body {
+			t.Errorf("%s: body mismatch, got %d bytes", tt.name, len(body))
+		}
+	}
+}
+
+//Content-Length已知并达到阈值时，第一次写入就开始压缩，并去掉Content-Length
+func TestCompressContentLength(t *testing.T) {
+	rec := compressServe(nil, "gzip", func(ctx *context.ThingoContext) {
+		ctx.Output.AddHeader("Content-Type", "text/plain")
+		ctx.Output.AddHeader("Content-Length", "2048")
+		ctx.Output.Write([]byte(strings.Repeat("a", 100)))
+		if ctx.Writer.Written() != true {
+			t.Error("compression did not start on the first write")
+		}
+		ctx.Output.Write([]byte(strings.Repeat("b", 1948)))
+	})
+	if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Content-Length") != "" {
+		t.Errorf("headers = %v", rec.Header())
+	}
+	if body := decodeBody(t, rec.Header(), rec.Body); len(body) != 2048 {
+		t.Errorf("body length = %d, want 2048", len(body))
+	}
+
+	//不压缩时保留Content-Length
+	rec = compressServe(nil, "", textHandler("text/plain", compressBody))
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != compressBody {
+		t.Errorf("identity: headers = %v", rec.Header())
+	}
+}
+
+func TestCompressTypes(t *testing.T) {
+	tests := []struct {
+		types       []string
+		contentType string
+		body        string
+		want        bool
+	}{
+		{nil, "text/html; charset=utf-8", compressBody, true},
+		{nil, "application/json", compressBody, true},
+		{nil, "application/problem+json", compressBody, true},
+		{nil, "application/atom+xml", compressBody, true},
+		{nil, "image/png", compressBody, false},
+		{nil, "application/octet-stream", compressBody, false},
+		{nil, "invalid/", compressBody, false},
+		//没有设置时按body识别
+		{nil, "", "<html><body>" + compressBody + "</body></html>", true},
+		{nil, "", "\x89PNG\r\n\x1a\n" + compressBody, false},
+		//自定义的类型，支持“x/*”
+		{[]string{"text/*"}, "text/markdown", compressBody, true},
+		{[]string{"text/*"}, "application/json", compressBody, false},
+		{[]string{"Application/JSON"}, "application/json", compressBody, true},
+	}
+	for _, tt := range tests {
+		rec := compressServe(&CompressOptions{Types: tt.types}, "gzip", textHandler(tt.contentType, tt.body))
+		got := rec.Header().Get("Content-Encoding") == "gzip"
+		if got != tt.want {
+			t.Errorf("types %v %q: compressed = %v, want %v", tt.types, tt.contentType, got, tt.want)
+		}
+		if vary := rec.Header().Get("Vary") != ""; vary != tt.want {
+			t.Errorf("types %v %q: Vary = %q", tt.types, tt.contentType, rec.Header().Get("Vary"))
+		}
+		if body := decodeBody(t, rec.Header(), rec.Body); body != tt.body {
+			t.Errorf("types %v %q: body mismatch", tt.types, tt.contentType)
+		}
+	}
+}
+
+//已有的Vary保留，不重复添加
+func TestCompressVary(t *testing.T) {
+	tests := []struct {
+		vary string
+		want []string
+	}{
+		{"Origin", []string{"Origin", "Accept-Encoding"}},
+		{"Origin, accept-encoding", []string{"Origin, accept-encoding"}},
+		{"*", []string{"*"}},
+	}
+	for _, tt := range tests {
+		rec := compressServe(nil, "gzip", func(ctx *context.ThingoContext) {
+			ctx.Output.AddHeader("Vary", tt.vary)
+			textHandler("text/plain", compressBody)(ctx)
+		})
+		if got := rec.Header().Values("Vary"); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("Vary %q: got %q, want %q", tt.vary, got, tt.want)
+		}
+	}
+}
+
+//压缩后强ETag转为弱ETag，不压缩时不变
+func TestCompressETag(t *testing.T) {
+	tests := []struct {
+		accept string
+		weak   bool
+		want   string
+	}{
+		{"gzip", false, `W/"v1"`},
+		{"gzip", true, `W/"v1"`},
+		{"", false, `"v1"`},
+	}
+	for _, tt := range tests {
+		rec := compressServe(nil, tt.accept, func(ctx *context.ThingoContext) {
+			ctx.Output.SetETag("v1", tt.weak)
+			textHandler("text/plain", compressBody)(ctx)
+		})
+		if got := rec.Header().Get("ETag"); got != tt.want {
+			t.Errorf("accept %q weak %v: ETag = %q, want %q", tt.accept, tt.weak, got, tt.want)
+		}
+	}
+}
+
+//没有完整body或已经编码过的响应不压缩
+func TestCompressSkip(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(ctx *context.ThingoContext)
+	}{
+		{"encoded", func(ctx *context.ThingoContext) {
+			ctx.Output.AddHeader("Content-Encoding", "br")
+			textHandler("text/plain", compressBody)(ctx)
+		}},
+		{"partial", func(ctx *context.ThingoContext) {
+			ctx.Output.SetStatus(http.StatusPartialContent)
+			ctx.Output.AddHeader("Content-Range", "bytes 0-1399/2000")
+			textHandler("text/plain", compressBody)(ctx)
+		}},
+		{"no content", func(ctx *context.ThingoContext) {
+			ctx.Output.SetStatus(http.StatusNoContent)
+		}},
+	}
+	for _, tt := range tests {
+		rec := compressServe(nil, "gzip", tt.fn)
+		if got := rec.Header().Get("Content-Encoding"); got == "gzip" {
+			t.Errorf("%s: response compressed", tt.name)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodHead, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	newCompressHandler(nil, textHandler("text/plain", compressBody)).ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Encoding") != "" {
+		t.Error("HEAD: response compressed")
+	}
+}
+
+//流式输出时每次Flush都把已压缩的数据发给客户端，不等请求结束
+func TestCompressFlush(t *testing.T) {
+	next := make(chan struct{})
+	cr := newCompressHandler(nil, func(ctx *context.ThingoContext) {
+		ctx.Output.AddHeader("Content-Type", "text/plain")
+		for i := 0; i < 3; i++ {
+			ctx.Output.Write([]byte("chunk\n"))
+			ctx.Output.Flush()
+			<-next
+		}
+	})
+	srv := httptest.NewServer(cr)
+	defer srv.Close()
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := (&http.Transport{DisableCompression: true}).RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding = %q", resp.Header.Get("Content-Encoding"))
+	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(gz)
+	for i := 0; i < 3; i++ {
+		line := make(chan string, 1)
+		go func() {
+			s, _ := br.ReadString('\n')
+			line <- s
+		}()
+		select {
+		case s := <-line:
+			if s != "chunk\n" {
+				t.Fatalf("chunk %d = %q", i, s)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("chunk %d not flushed", i)
+		}
+		next <- struct{}{}
+	}
+	if rest, _ := ioutil.ReadAll(br); len(rest) != 0 {
+		t.Errorf("unexpected trailing data %q", rest)
+	}
+}
+
+//并发请求时gzip压缩器的复用
+func TestCompressParallel(t *testing.T) {
+	cr := newCompressHandler(&CompressOptions{Level: gzip.BestSpeed}, textHandler("text/plain", compressBody))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Accept-Encoding", "gzip")
+				rec := httptest.NewRecorder()
+				cr.ServeHTTP(rec, req)
+				gz, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Errorf("gzip: %v", err)
+					return
+				}
+				if body, _ := ioutil.ReadAll(gz); string(body) != compressBody {
+					t.Errorf("body mismatch, got %d bytes", len(body))
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCompressInvalidLevel(t *testing.T) {
+	rec := compressServe(&CompressOptions{Level: 42}, "gzip", textHandler("text/plain", compressBody))
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != compressBody {
+		t.Errorf("invalid level: headers = %v", rec.Header())
+	}
+}
@@ -0,0 +1,112 @@
+/**
+ * @author      Liu Yongshuai<liuyongshuai@hotmail.com>
+ * @package     middleware
+ * @date        2026-10-17 22:10
+ */
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/liuyongshuai/thingo/context"
+	"github.com/liuyongshuai/thingo/router"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//CSP里的nonce占位符，每个请求替换为新生成的nonce
+const CSPNoncePlaceholder = "{nonce}"
+
+//安全相关头信息的配置，为空的不输出
+type SecureOptions struct {
+	HSTSMaxAge            time.Duration //Strict-Transport-Security的max-age，为0时不输出，只在HTTPS请求上输出
+	HSTSIncludeSubdomains bool          //HSTS是否包括子域名
+	HSTSPreload           bool          //HSTS是否带preload
+	ContentSecurityPolicy string        //CSP，里面的“{nonce}”会替换为本次请求的nonce
+	CSPReportOnly         bool          //只上报不拦截，输出为Content-Security-Policy-Report-Only
+	ContentTypeNosniff    bool          //输出“X-Content-Type-Options: nosniff”
+	FrameOptions          string        //X-Frame-Options，如DENY、SAMEORIGIN
+	ReferrerPolicy        string        //Referrer-Policy，会覆盖默认头信息里的
+	PermissionsPolicy     string        //Permissions-Policy，如“camera=(), microphone=()”
+}
+
+/**
+默认的配置，内联的脚本要带上nonce才能执行，模板里这样用：
+	<script nonce="{{.CSP_NONCE}}">...</script>
+*/
+var DefaultSecureOptions = SecureOptions{
+	HSTSMaxAge:            365 * 24 * time.Hour,
+	HSTSIncludeSubdomains: true,
+	ContentSecurityPolicy: "default-src 'self'; script-src 'self' 'nonce-{nonce}'; object-src 'none'; base-uri 'self'; frame-ancestors 'self'",
+	ContentTypeNosniff:    true,
+	FrameOptions:          "SAMEORIGIN",
+	ReferrerPolicy:        "strict-origin-when-cross-origin",
+	PermissionsPolicy:     "camera=(), microphone=(), geolocation=()",
+}
+
+/**
+输出安全相关的头信息，opts为nil时用DefaultSecureOptions，如：
+	app.Use(middleware.Secure(nil))
+
+头信息在执行控制层之前设置，控制层里可以再覆盖
+CSP里有“{nonce}”时每个请求生成一个nonce，放在ctx.CSPNonce里，控制层的模板里为CSP_NONCE
+*/
+func Secure(opts *SecureOptions) router.MiddlewareFunc {
+	cfg := DefaultSecureOptions
+	if opts != nil {
+		cfg = *opts
+	}
+	var hsts string
+	if cfg.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.FormatInt(int64(cfg.HSTSMaxAge/time.Second), 10)
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	cspHeader := "Content-Security-Policy"
+	if cfg.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	useNonce := strings.Contains(cfg.ContentSecurityPolicy, CSPNoncePlaceholder)
+
+	return func(ctx *context.ThingoContext, next func()) {
+		header := ctx.ResponseWriter.Header()
+		//HSTS只能在HTTPS上输出，HTTP上的会被浏览器忽略
+		if hsts != "" && ctx.Input.Scheme() == "https" {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+		if csp := cfg.ContentSecurityPolicy; csp != "" {
+			if useNonce {
+				ctx.CSPNonce = newCSPNonce()
+				csp = strings.Replace(csp, CSPNoncePlaceholder, ctx.CSPNonce, -1)
+			}
+			header.Set(cspHeader, csp)
+		}
+		if cfg.ContentTypeNosniff {
+			header.Set("X-Content-Type-Options", "nosniff")
+		}
+		if cfg.FrameOptions != "" {
+			header.Set("X-Frame-Options", cfg.FrameOptions)
+		}
+		if cfg.ReferrerPolicy != "" {
+			header.Set("Referrer-Policy", cfg.ReferrerPolicy)
+		}
+		if cfg.PermissionsPolicy != "" {
+			header.Set("Permissions-Policy", cfg.PermissionsPolicy)
+		}
+		next()
+	}
+}
+
+//生成一个随机的nonce，128位
+func newCSPNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
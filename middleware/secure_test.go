@@ -0,0 +1,106 @@
+/**
+ * @author      Liu Yongshuai<liuyongshuai@hotmail.com>
+ * @package     middleware
+ * @date        2026-10-18 18:40
+ */
+package middleware
+
+import (
+	goweb "github.com/liuyongshuai/thingo"
+	"github.com/liuyongshuai/thingo/context"
+	"github.com/liuyongshuai/thingo/router"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//按url请求一次带Secure中间件的处理器，fn为控制层
+func secureServe(opts *SecureOptions, url string, fn func(ctx *context.ThingoContext)) *httptest.ResponseRecorder {
+	cr := goweb.NewThingoHandler()
+	cr.Use(Secure(opts))
+	cr.AddRouter(&router.ThingoRouterItem{
+		Type:    router.RouterTypePathInfo,
+		Config:  "/",
+		Handler: router.ThingoHandlerFunc(fn),
+	})
+	rec := httptest.NewRecorder()
+	cr.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+	return rec
+}
+
+func TestSecureDefault(t *testing.T) {
+	var nonce string
+	rec := secureServe(nil, "https://example.com/", func(ctx *context.ThingoContext) {
+		nonce = ctx.CSPNonce
+	})
+	if len(nonce) != 24 {
+		t.Fatalf("CSPNonce = %q", nonce)
+	}
+	want := map[string]string{
+		"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+		"Content-Security-Policy":   "default-src 'self'; script-src 'self' 'nonce-" + nonce + "'; object-src 'none'; base-uri 'self'; frame-ancestors 'self'",
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "SAMEORIGIN",
+		//覆盖默认头信息里的
+		"Referrer-Policy":    "strict-origin-when-cross-origin",
+		"Permissions-Policy": "camera=(), microphone=(), geolocation=()",
+	}
+	for k, v := range want {
+		if got := rec.Header().Values(k); len(got) != 1 || got[0] != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+
+	//HSTS只在HTTPS上输出，nonce每个请求都不一样
+	rec = secureServe(nil, "http://example.com/", func(ctx *context.ThingoContext) {})
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("HSTS over http: %q", got)
+	}
+	if csp := rec.Header().Get("Content-Security-Policy"); csp == "" || strings.Contains(csp, nonce) || strings.Contains(csp, CSPNoncePlaceholder) {
+		t.Errorf("second CSP = %q", csp)
+	}
+}
+
+func TestSecureOptions(t *testing.T) {
+	opts := &SecureOptions{
+		HSTSMaxAge:            time.Hour,
+		HSTSPreload:           true,
+		ContentSecurityPolicy: "default-src 'none'",
+		CSPReportOnly:         true,
+	}
+	var nonce string
+	rec := secureServe(opts, "https://example.com/", func(ctx *context.ThingoContext) {
+		nonce = ctx.CSPNonce
+	})
+	want := map[string]string{
+		"Strict-Transport-Security":           "max-age=3600; preload",
+		"Content-Security-Policy-Report-Only": "default-src 'none'",
+		"Content-Security-Policy":             "",
+		"X-Content-Type-Options":              "",
+		"X-Frame-Options":                     "",
+		"Permissions-Policy":                  "",
+		//没有设置时保留默认头信息里的
+		"Referrer-Policy": "origin-when-cross-origin",
+	}
+	for k, v := range want {
+		if got := rec.Header().Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+	//CSP里没有占位符时不生成nonce
+	if nonce != "" {
+		t.Errorf("CSPNonce = %q", nonce)
+	}
+}
+
+//控制层里可以覆盖中间件设置的头信息
+func TestSecureOverride(t *testing.T) {
+	rec := secureServe(nil, "https://example.com/", func(ctx *context.ThingoContext) {
+		ctx.Output.AddHeader("X-Frame-Options", "DENY")
+		ctx.ResponseWriter.Header().Del("Content-Security-Policy")
+	})
+	if rec.Header().Get("X-Frame-Options") != "DENY" || rec.Header().Get("Content-Security-Policy") != "" {
+		t.Errorf("headers = %v", rec.Header())
+	}
+}
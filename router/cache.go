@@ -0,0 +1,151 @@
+/**
+ * @author      Liu Yongshuai<liuyongshuai@hotmail.com>
+ * @package     router
+ * @date        2026-10-17 11:03
+ */
+package router
+
+import (
+	"container/list"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	//默认缓存的路由条数
+	DefaultRouteCacheSize = 4096
+	//缓存的分片数，降低锁的竞争
+	routeCacheShards = 16
+)
+
+//路由缓存的统计信息
+type ThingoRouteCacheStats struct {
+	Hits      uint64 //命中次数
+	Misses    uint64 //未命中次数
+	Evictions uint64 //因超出容量被淘汰的条数
+	Size      int    //当前缓存的条数
+	Capacity  int    //最大容量
+}
+
+//分片的LRU路由缓存，key一般为“方法 路径”
+type ThingoRouteCache struct {
+	shards     [routeCacheShards]*routeCacheShard
+	capacity   int  //总容量，小于等于0时不缓存
+	staticOnly bool //是否只缓存不带参数的路由
+	hits       uint64
+	misses     uint64
+	evictions  uint64
+}
+
+//单个分片
+type routeCacheShard struct {
+	lock     sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List //越靠前越是最近使用的
+}
+
+//分片里的单条缓存
+type routeCacheEntry struct {
+	key string
+	val ThingoRouterCache
+}
+
+//新建一个路由缓存，size小于等于0时表示不缓存
+func NewThingoRouteCache(size int, staticOnly bool) *ThingoRouteCache {
+	c := &ThingoRouteCache{capacity: size, staticOnly: staticOnly}
+	per := 0
+	if size > 0 {
+		per = (size + routeCacheShards - 1) / routeCacheShards
+	}
+	for i := range c.shards {
+		c.shards[i] = &routeCacheShard{
+			capacity: per,
+			items:    make(map[string]*list.Element),
+			order:    list.New(),
+		}
+	}
+	return c
+}
+
+//按key选取分片
+func (c *ThingoRouteCache) shard(key string) *routeCacheShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return c.shards[h.Sum32()%routeCacheShards]
+}
+
+//提取缓存
+func (c *ThingoRouteCache) Get(key string) (ThingoRouterCache, bool) {
+	if c.capacity <= 0 {
+		return ThingoRouterCache{}, false
+	}
+	s := c.shard(key)
+	s.lock.Lock()
+	var val ThingoRouterCache
+	elem, ok := s.items[key]
+	if ok {
+		s.order.MoveToFront(elem)
+		//Set会修改同一条缓存，要在锁内复制出来
+		val = elem.Value.(*routeCacheEntry).val
+	}
+	s.lock.Unlock()
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return ThingoRouterCache{}, false
+	}
+	atomic.AddUint64(&c.hits, 1)
+	return val, true
+}
+
+//写入缓存，超出容量时淘汰最久未使用的
+func (c *ThingoRouteCache) Set(key string, val ThingoRouterCache) {
+	if c.capacity <= 0 || val.R == nil {
+		return
+	}
+	if c.staticOnly && !val.R.isStatic() {
+		return
+	}
+	s := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if elem, ok := s.items[key]; ok {
+		elem.Value.(*routeCacheEntry).val = val
+		s.order.MoveToFront(elem)
+		return
+	}
+	s.items[key] = s.order.PushFront(&routeCacheEntry{key: key, val: val})
+	for s.order.Len() > s.capacity {
+		last := s.order.Back()
+		s.order.Remove(last)
+		delete(s.items, last.Value.(*routeCacheEntry).key)
+		atomic.AddUint64(&c.evictions, 1)
+	}
+}
+
+//清空缓存，统计信息保留
+func (c *ThingoRouteCache) Clear() {
+	for _, s := range c.shards {
+		s.lock.Lock()
+		s.items = make(map[string]*list.Element)
+		s.order.Init()
+		s.lock.Unlock()
+	}
+}
+
+//当前的统计信息
+func (c *ThingoRouteCache) Stats() ThingoRouteCacheStats {
+	st := ThingoRouteCacheStats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+		Capacity:  c.capacity,
+	}
+	for _, s := range c.shards {
+		s.lock.Lock()
+		st.Size += s.order.Len()
+		s.lock.Unlock()
+	}
+	return st
+}
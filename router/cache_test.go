@@ -0,0 +1,142 @@
+/**
+ * @author      Liu Yongshuai<liuyongshuai@hotmail.com>
+ * @package     router
+ * @date        2026-10-18 10:12
+ */
+package router
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+//测试用的路由项，静态路由
+func cacheTestRouter(name string) *ThingoRouterItem {
+	return &ThingoRouterItem{Type: RouterTypePathInfo, Config: name}
+}
+
+//找出n个落在同一个分片里的key
+func sameShardKeys(c *ThingoRouteCache, n int) []string {
+	target := c.shard("k0")
+	keys := []string{"k0"}
+	for i := 1; len(keys) < n; i++ {
+		k := fmt.Sprintf("k%d", i)
+		if c.shard(k) == target {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func TestRouteCacheEvictionOrder(t *testing.T) {
+	//每个分片的容量为2
+	c := NewThingoRouteCache(2*routeCacheShards, false)
+	keys := sameShardKeys(c, 3)
+	for _, k := range keys[:2] {
+		c.Set(k, ThingoRouterCache{R: cacheTestRouter(k)})
+	}
+	//访问第一个后，第二个就是最久未使用的
+	if _, ok := c.Get(keys[0]); !ok {
+		t.Fatalf("Get(%q) missed", keys[0])
+	}
+	c.Set(keys[2], ThingoRouterCache{R: cacheTestRouter(keys[2])})
+	if _, ok := c.Get(keys[1]); ok {
+		t.Errorf("Get(%q) should be evicted", keys[1])
+	}
+	for _, k := range []string{keys[0], keys[2]} {
+		if v, ok := c.Get(k); !ok || v.R.Config != k {
+			t.Errorf("Get(%q) = %v, %v", k, v.R, ok)
+		}
+	}
+	if st := c.Stats(); st.Evictions != 1 || st.Size != 2 {
+		t.Errorf("stats = %+v, want 1 eviction and size 2", st)
+	}
+}
+
+func TestRouteCacheCapacity(t *testing.T) {
+	c := NewThingoRouteCache(64, false)
+	for i := 0; i < 1000; i++ {
+		k := fmt.Sprintf("GET /item/%d", i)
+		c.Set(k, ThingoRouterCache{R: cacheTestRouter(k)})
+	}
+	st := c.Stats()
+	if st.Size > 64 {
+		t.Errorf("size = %d, want <= 64", st.Size)
+	}
+	if st.Capacity != 64 {
+		t.Errorf("capacity = %d, want 64", st.Capacity)
+	}
+	if int(st.Evictions)+st.Size != 1000 {
+		t.Errorf("evictions %d + size %d != 1000", st.Evictions, st.Size)
+	}
+
+	//容量小于等于0时不缓存
+	c = NewThingoRouteCache(0, false)
+	c.Set("a", ThingoRouterCache{R: cacheTestRouter("a")})
+	if _, ok := c.Get("a"); ok {
+		t.Error("cache with size 0 should not store")
+	}
+}
+
+func TestRouteCacheReplace(t *testing.T) {
+	c := NewThingoRouteCache(2*routeCacheShards, false)
+	keys := sameShardKeys(c, 3)
+	c.Set(keys[0], ThingoRouterCache{R: cacheTestRouter("old")})
+	c.Set(keys[1], ThingoRouterCache{R: cacheTestRouter(keys[1])})
+	//替换已有的key不会增加条数，且会变为最近使用的
+	c.Set(keys[0], ThingoRouterCache{R: cacheTestRouter("new")})
+	if st := c.Stats(); st.Size != 2 {
+		t.Errorf("size = %d, want 2", st.Size)
+	}
+	c.Set(keys[2], ThingoRouterCache{R: cacheTestRouter(keys[2])})
+	v, ok := c.Get(keys[0])
+	if !ok || v.R.Config != "new" {
+		t.Errorf("Get(%q) = %v, %v, want new", keys[0], v.R, ok)
+	}
+	if _, ok := c.Get(keys[1]); ok {
+		t.Errorf("Get(%q) should be evicted", keys[1])
+	}
+}
+
+func TestRouteCacheStaticOnly(t *testing.T) {
+	c := NewThingoRouteCache(16, true)
+	dyn := cacheTestRouter("/item/:id")
+	dyn.paramNames = []string{"id"}
+	c.Set("dyn", ThingoRouterCache{R: dyn})
+	c.Set("static", ThingoRouterCache{R: cacheTestRouter("/about")})
+	if _, ok := c.Get("dyn"); ok {
+		t.Error("router with params should not be cached when staticOnly")
+	}
+	if _, ok := c.Get("static"); !ok {
+		t.Error("static router should be cached")
+	}
+}
+
+//用 go test -race 运行时检查并发读写
+func TestRouteCacheParallel(t *testing.T) {
+	c := NewThingoRouteCache(1024, false)
+	routers := make([]*ThingoRouterItem, 8)
+	for i := range routers {
+		routers[i] = cacheTestRouter(fmt.Sprint(i))
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				k := fmt.Sprintf("k%d", i%64)
+				if i%3 == 0 {
+					c.Set(k, ThingoRouterCache{R: routers[(g+i)%len(routers)]})
+				} else if v, ok := c.Get(k); ok && v.R == nil {
+					t.Errorf("Get(%q) returned nil router", k)
+				}
+				if i%500 == 0 {
+					c.Stats()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
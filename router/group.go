@@ -0,0 +1,141 @@
+/**
+ * @author      Liu Yongshuai<liuyongshuai@hotmail.com>
+ * @package     router
+ * @date        2026-10-17 11:40
+ */
+package router
+
+import (
+	"github.com/liuyongshuai/thingo/context"
+	"regexp"
+	"strings"
+)
+
+//分组上的插件函数
+type HookFunc func(ctx *context.ThingoContext)
+
+/**
+路由分组，组内的路由共用路径前缀、域名限制、前后插件及中间件，可以嵌套，如：
+	api := rs.Group("api/v1").Before(checkLogin).Use(timing)
+	api.AddRouter(&ThingoRouterItem{Type: RouterTypePathInfo, Config: "user/:id", Controller: &UserController{}})
+	admin := api.Group("admin").SetHost("admin.example.com")
+*/
+type ThingoRouterGroup struct {
+	list        *ThingoRouterList  //所属的路由列表
+	parent      *ThingoRouterGroup //上级分组
+	prefix      string             //完整的路径前缀，不带首尾的“/”
+	host        string             //限定的域名，为空时沿用上级分组的
+	before      []HookFunc         //本组在控制层之前执行的插件
+	after       []HookFunc         //本组在控制层之后执行的插件
+	middlewares []MiddlewareFunc   //本组的中间件
+}
+
+//新建一个路由分组
+func (rs *ThingoRouterList) Group(prefix string) *ThingoRouterGroup {
+	return &ThingoRouterGroup{
+		list:   rs,
+		prefix: strings.Trim(prefix, "/"),
+	}
+}
+
+//新建一个子分组，前缀会拼在当前分组的后面
+func (g *ThingoRouterGroup) Group(prefix string) *ThingoRouterGroup {
+	return &ThingoRouterGroup{
+		list:   g.list,
+		parent: g,
+		prefix: joinPathPrefix(g.prefix, prefix),
+	}
+}
+
+//设置分组限定的域名，支持 :tenant.example.com 这样提取子域名作为参数
+func (g *ThingoRouterGroup) SetHost(host string) *ThingoRouterGroup {
+	g.host = host
+	return g
+}
+
+//添加在控制层之前执行的插件
+func (g *ThingoRouterGroup) Before(hks ...HookFunc) *ThingoRouterGroup {
+	g.before = append(g.before, hks...)
+	return g
+}
+
+//添加在控制层之后执行的插件
+func (g *ThingoRouterGroup) After(hks ...HookFunc) *ThingoRouterGroup {
+	g.after = append(g.after, hks...)
+	return g
+}
+
+//完整的路径前缀
+func (g *ThingoRouterGroup) Prefix() string {
+	return g.prefix
+}
+
+//分组限定的域名，自己没设置时沿用上级分组的
+func (g *ThingoRouterGroup) Host() string {
+	for p := g; p != nil; p = p.parent {
+		if p.host != "" {
+			return p.host
+		}
+	}
+	return ""
+}
+
+//添加本组的中间件，在分组的前后插件里面执行
+func (g *ThingoRouterGroup) Use(mws ...MiddlewareFunc) *ThingoRouterGroup {
+	g.middlewares = append(g.middlewares, mws...)
+	return g
+}
+
+//本组路由要执行的所有中间件，外层分组的在前，前后插件包在本组中间件的外面
+func (g *ThingoRouterGroup) Middlewares() []MiddlewareFunc {
+	var ret []MiddlewareFunc
+	if g.parent != nil {
+		ret = g.parent.Middlewares()
+	}
+	if len(g.before) > 0 || len(g.after) > 0 {
+		ret = append(ret, HooksMiddleware(g.before, g.after))
+	}
+	return append(ret, g.middlewares...)
+}
+
+//往分组里添加路由，会把分组的前缀拼到路由的配置上
+func (g *ThingoRouterGroup) AddRouter(r *ThingoRouterItem) *ThingoRouterGroup {
+	if r == nil {
+		return g
+	}
+	if g.prefix != "" {
+		switch r.Type {
+		case RouterTypePathInfo:
+			r.Config = joinPathPrefix(g.prefix, r.Config)
+		case RouterTypeRegexp:
+			r.Config = "^" + regexp.QuoteMeta(g.prefix) + "/" + strings.TrimLeft(strings.TrimPrefix(r.Config, "^"), "/")
+		}
+	}
+	if r.Host == "" {
+		r.Host = g.Host()
+	}
+	r.Group = g
+	g.list.AddRouter(r)
+	return g
+}
+
+//往分组里批量添加路由
+func (g *ThingoRouterGroup) AddRouters(rs ...*ThingoRouterItem) *ThingoRouterGroup {
+	for _, r := range rs {
+		g.AddRouter(r)
+	}
+	return g
+}
+
+//拼接两段路径
+func joinPathPrefix(prefix, path string) string {
+	prefix = strings.Trim(prefix, "/")
+	path = strings.Trim(path, "/")
+	if prefix == "" {
+		return path
+	}
+	if path == "" {
+		return prefix
+	}
+	return prefix + "/" + path
+}
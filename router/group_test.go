@@ -0,0 +1,138 @@
+/**
+ * @author      Liu Yongshuai<liuyongshuai@hotmail.com>
+ * @package     router
+ * @date        2026-10-18 17:20
+ */
+package router
+
+import (
+	"github.com/liuyongshuai/thingo/context"
+	"reflect"
+	"testing"
+)
+
+//记录执行顺序的中间件
+func traceMiddleware(trace *[]string, name string) MiddlewareFunc {
+	return func(ctx *context.ThingoContext, next func()) {
+		*trace = append(*trace, name+" in")
+		next()
+		*trace = append(*trace, name+" out")
+	}
+}
+
+//记录执行顺序的插件
+func traceHook(trace *[]string, name string) HookFunc {
+	return func(*context.ThingoContext) {
+		*trace = append(*trace, name)
+	}
+}
+
+func TestGroupPrefix(t *testing.T) {
+	rs := NewThingoRouterList()
+	api := rs.Group("/api/")
+	v1 := api.Group("/v1")
+	user := &ThingoRouterItem{Type: RouterTypePathInfo, Config: "/users/:id<int>", Handler: ThingoHandlerFunc(noopHandler)}
+	item := &ThingoRouterItem{Type: RouterTypeRegexp, Config: `^items/([0-9]+)$`, Param: "id=$1", Handler: ThingoHandlerFunc(noopHandler)}
+	root := &ThingoRouterItem{Type: RouterTypePathInfo, Config: "/", Handler: ThingoHandlerFunc(noopHandler)}
+	v1.AddRouters(user, item, root)
+	if api.Prefix() != "api" || v1.Prefix() != "api/v1" {
+		t.Errorf("prefix = %q, %q", api.Prefix(), v1.Prefix())
+	}
+	tests := []struct {
+		path string
+		want *ThingoRouterItem
+		id   string
+	}{
+		{"/api/v1/users/3", user, "3"},
+		{"/api/v1/items/5", item, "5"},
+		{"/api/v1", root, ""},
+		{"/users/3", nil, ""},
+		{"/items/5", nil, ""},
+		{"/api/users/3", nil, ""},
+	}
+	for _, tt := range tests {
+		ctx := newTestContext("GET", tt.path)
+		r, _ := rs.Lookup(ctx, ctx.Request)
+		if r != tt.want {
+			t.Errorf("%s: matched %v", tt.path, r)
+			continue
+		}
+		if r != nil && r.Group != v1 {
+			t.Errorf("%s: group = %v", tt.path, r.Group)
+		}
+		if got := ctx.Input.Param("id"); got != tt.id {
+			t.Errorf("%s: id = %q, want %q", tt.path, got, tt.id)
+		}
+	}
+}
+
+//外层分组的在前，每个分组的前后插件包在本组中间件的外面
+func TestGroupMiddlewareOrder(t *testing.T) {
+	var trace []string
+	rs := NewThingoRouterList()
+	outer := rs.Group("a").Before(traceHook(&trace, "a before")).After(traceHook(&trace, "a after")).Use(traceMiddleware(&trace, "a mw"))
+	inner := outer.Group("b").Use(traceMiddleware(&trace, "b mw1"), traceMiddleware(&trace, "b mw2")).Before(traceHook(&trace, "b before"))
+	inner.After(traceHook(&trace, "b after"))
+	ctx := newTestContext("GET", "/a/b")
+	RunMiddlewares(ctx, inner.Middlewares(), func() {
+		trace = append(trace, "handler")
+	})
+	want := []string{
+		"a before", "a mw in",
+		"b before", "b mw1 in", "b mw2 in",
+		"handler",
+		"b mw2 out", "b mw1 out", "b after",
+		"a mw out", "a after",
+	}
+	if !reflect.DeepEqual(trace, want) {
+		t.Errorf("trace = %v\nwant %v", trace, want)
+	}
+}
+
+//Before插件把Started置为true时，后面的插件、中间件、控制层及本组的After插件都不再执行，外层分组的After照常执行
+func TestGroupHooksAbort(t *testing.T) {
+	var trace []string
+	rs := NewThingoRouterList()
+	outer := rs.Group("a").Before(traceHook(&trace, "a before")).After(traceHook(&trace, "a after"))
+	inner := outer.Group("b").Before(func(ctx *context.ThingoContext) {
+		trace = append(trace, "b abort")
+		ctx.Output.Started = true
+	}, traceHook(&trace, "b before")).After(traceHook(&trace, "b after")).Use(traceMiddleware(&trace, "b mw"))
+	ran := false
+	RunMiddlewares(newTestContext("GET", "/a/b"), inner.Middlewares(), func() {
+		ran = true
+	})
+	want := []string{"a before", "b abort", "a after"}
+	if ran || !reflect.DeepEqual(trace, want) {
+		t.Errorf("handler ran %v, trace = %v, want %v", ran, trace, want)
+	}
+}
+
+//分组的域名对组内的路由生效，子分组沿用上级的，路由自己设置了时不覆盖
+func TestGroupHost(t *testing.T) {
+	rs := NewThingoRouterList()
+	admin := rs.Group("admin").SetHost("admin.example.com")
+	sub := admin.Group("users")
+	list := &ThingoRouterItem{Type: RouterTypePathInfo, Config: "list", Handler: ThingoHandlerFunc(noopHandler)}
+	own := &ThingoRouterItem{Type: RouterTypePathInfo, Config: "own", Host: ":t.example.com", Handler: ThingoHandlerFunc(noopHandler)}
+	sub.AddRouters(list, own)
+	if sub.Host() != "admin.example.com" || list.Host != "admin.example.com" || own.Host != ":t.example.com" {
+		t.Fatalf("hosts = %q, %q, %q", sub.Host(), list.Host, own.Host)
+	}
+	tests := []struct {
+		url  string
+		want *ThingoRouterItem
+	}{
+		{"http://admin.example.com/admin/users/list", list},
+		{"http://ADMIN.example.com:8080/admin/users/list", list},
+		{"http://www.example.com/admin/users/list", nil},
+		{"http://acme.example.com/admin/users/own", own},
+		{"http://acme.example.org/admin/users/own", nil},
+	}
+	for _, tt := range tests {
+		ctx := newTestContext("GET", tt.url)
+		if r, _ := rs.Lookup(ctx, ctx.Request); r != tt.want {
+			t.Errorf("%s: matched %v", tt.url, r)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+/**
+ * @author      Liu Yongshuai<liuyongshuai@hotmail.com>
+ * @package     router
+ * @date        2026-10-17 16:25
+ */
+package router
+
+import (
+	"fmt"
+	"github.com/liuyongshuai/negoutils/comutils"
+	"strings"
+)
+
+//域名里的单个标签
+type hostLabel struct {
+	value    string      //静态标签，已转为小写
+	name     string      //参数名称，为空时表示静态标签
+	check    *paramCheck //参数的校验规则
+	wildcard bool        //是否为“*”，匹配任意一个标签但不提取参数
+}
+
+/**
+解析后的域名规则，按“.”切成多个标签逐个比对，如：
+	api.example.com				只匹配该域名
+	:tenant.example.com			提取子域名作为参数tenant
+	:tenant<[a-z0-9]+>.example.com	子域名要满足正则
+	*.example.com				匹配任意一级子域名
+端口会被忽略，不区分大小写
+*/
+type hostPattern struct {
+	labels []hostLabel
+	params int //参数标签的个数
+}
+
+//解析域名规则
+func parseHostPattern(pattern string) (*hostPattern, error) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if pos := strings.LastIndex(pattern, ":"); pos > 0 && comutils.IsAllNumber(pattern[pos+1:]) {
+		pattern = pattern[:pos]
+	}
+	if pattern == "" {
+		return nil, fmt.Errorf("empty host pattern")
+	}
+	hp := &hostPattern{}
+	for _, l := range strings.Split(pattern, ".") {
+		switch {
+		case l == "*":
+			hp.labels = append(hp.labels, hostLabel{wildcard: true})
+		case strings.HasPrefix(l, ":"):
+			seg, err := parseParamSegment(strings.TrimSuffix(l, ":"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid host label %q in %q: %v", l, pattern, err)
+			}
+			//域名里的 :name 可以匹配任意标签，不再限定为数字
+			if seg.legacy {
+				seg.check = checkAny
+			}
+			hp.labels = append(hp.labels, hostLabel{name: seg.value, check: seg.check})
+			hp.params++
+		case l == "":
+			return nil, fmt.Errorf("empty label in host pattern %q", pattern)
+		default:
+			hp.labels = append(hp.labels, hostLabel{value: l})
+		}
+	}
+	return hp, nil
+}
+
+//是否匹配请求的域名，域名已去掉端口并转为小写
+func (hp *hostPattern) match(host string) bool {
+	_, ok := hp.extract(host, false)
+	return ok
+}
+
+//匹配请求的域名并提取参数
+func (hp *hostPattern) extract(host string, withParams bool) ([]ThingoRouterParam, bool) {
+	labels := strings.Split(host, ".")
+	if len(labels) != len(hp.labels) {
+		return nil, false
+	}
+	var params []ThingoRouterParam
+	for i, l := range hp.labels {
+		switch {
+		case l.wildcard:
+		case l.name != "":
+			if labels[i] == "" || !l.check.fn(labels[i]) {
+				return nil, false
+			}
+			if withParams {
+				params = append(params, ThingoRouterParam{Key: l.name, Value: labels[i]})
+			}
+		case l.value != labels[i]:
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+//静态的域名规则优先，其次是带参数的，最后是不限域名的
+func (hp *hostPattern) rank() int {
+	if hp == nil {
+		return 2
+	}
+	if hp.params > 0 || hp.hasWildcard() {
+		return 1
+	}
+	return 0
+}
+
+//是否含有“*”
+func (hp *hostPattern) hasWildcard() bool {
+	for _, l := range hp.labels {
+		if l.wildcard {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+/**
+ * @author      Liu Yongshuai<liuyongshuai@hotmail.com>
+ * @package     router
+ * @date        2026-10-18 17:45
+ */
+package router
+
+import (
+	"reflect"
+	"testing"
+)
+
+//新建一条限定域名的路由
+func newHostRouter(host, config string) *ThingoRouterItem {
+	return &ThingoRouterItem{Type: RouterTypePathInfo, Config: config, Host: host, Handler: ThingoHandlerFunc(noopHandler)}
+}
+
+func TestHostPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		host    string
+		match   bool
+		params  []ThingoRouterParam
+	}{
+		{"api.example.com", "api.example.com", true, nil},
+		{"API.Example.com", "api.example.com", true, nil},
+		{"api.example.com:8080", "api.example.com", true, nil},
+		{"api.example.com", "www.example.com", false, nil},
+		{"api.example.com", "a.api.example.com", false, nil},
+		{":tenant.example.com", "acme.example.com", true, []ThingoRouterParam{{Key: "tenant", Value: "acme"}}},
+		{":tenant:.example.com", "acme.example.com", true, []ThingoRouterParam{{Key: "tenant", Value: "acme"}}},
+		{":tenant.example.com", "example.com", false, nil},
+		{":id<int>.example.com", "42.example.com", true, []ThingoRouterParam{{Key: "id", Value: "42"}}},
+		{":id<int>.example.com", "abc.example.com", false, nil},
+		{":t<[a-z]+>.:r.example.com", "acme.eu.example.com", true, []ThingoRouterParam{{Key: "t", Value: "acme"}, {Key: "r", Value: "eu"}}},
+		{"*.example.com", "www.example.com", true, nil},
+		{"*.example.com", "example.com", false, nil},
+	}
+	for _, tt := range tests {
+		hp, err := parseHostPattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("parseHostPattern(%q): %v", tt.pattern, err)
+		}
+		params, ok := hp.extract(tt.host, true)
+		if ok != tt.match || !reflect.DeepEqual(params, tt.params) {
+			t.Errorf("%q on %q: got %v %v, want %v %v", tt.pattern, tt.host, ok, params, tt.match, tt.params)
+		}
+	}
+	for _, p := range []string{"", "a..com", ":<int>.example.com", ":id<uiid>.example.com"} {
+		if _, err := parseHostPattern(p); err == nil {
+			t.Errorf("parseHostPattern(%q) should fail", p)
+		}
+	}
+}
+
+func TestHostLookup(t *testing.T) {
+	rs := NewThingoRouterList()
+	static := newHostRouter("api.example.com", "/x")
+	tenant := newHostRouter(":tenant.example.com", "/x")
+	free := newHostRouter("", "/y")
+	rs.AddRouters(static, tenant, free)
+	tests := []struct {
+		url    string
+		want   *ThingoRouterItem
+		tenant string
+	}{
+		//静态的域名优先于带参数的
+		{"http://api.example.com/x", static, ""},
+		{"http://acme.example.com/x", tenant, "acme"},
+		//去掉端口，不区分大小写
+		{"http://Acme.Example.com:8080/x", tenant, "acme"},
+		{"http://api.example.com:443/x", static, ""},
+		{"http://example.org/x", nil, ""},
+		{"http://a.b.example.com/x", nil, ""},
+		//不限域名的路由
+		{"http://example.org/y", free, ""},
+	}
+	for _, tt := range tests {
+		ctx := newTestContext("GET", tt.url)
+		r, allowed := rs.Lookup(ctx, ctx.Request)
+		if r != tt.want {
+			t.Errorf("%s: matched %v, allowed %v", tt.url, r, allowed)
+			continue
+		}
+		if tt.want == nil && allowed != nil {
+			t.Errorf("%s: allowed = %v, want nil", tt.url, allowed)
+		}
+		if got := ctx.Input.Param("tenant"); got != tt.tenant {
+			t.Errorf("%s: tenant = %q, want %q", tt.url, got, tt.tenant)
+		}
+	}
+}
+
+//请求的域名匹配不上任何路由时，按默认域名匹配，域名里的参数取自默认域名
+func TestDefaultHost(t *testing.T) {
+	rs := NewThingoRouterList()
+	home := newHostRouter(":tenant.example.com", "/home")
+	about := newHostRouter("www.example.com", "/about")
+	rs.AddRouters(home, about)
+	rs.SetDefaultHost("Demo.Example.com:80")
+	tests := []struct {
+		url    string
+		want   *ThingoRouterItem
+		tenant string
+	}{
+		{"http://acme.example.com/home", home, "acme"},
+		{"http://localhost:8080/home", home, "demo"},
+		{"http://10.0.0.1/home", home, "demo"},
+		//默认域名也匹配不上
+		{"http://localhost/about", nil, ""},
+		{"http://www.example.com/about", about, ""},
+	}
+	for _, tt := range tests {
+		//第二次走缓存
+		for i := 0; i < 2; i++ {
+			ctx := newTestContext("GET", tt.url)
+			r, _ := rs.Lookup(ctx, ctx.Request)
+			if r != tt.want {
+				t.Errorf("%s: matched %v", tt.url, r)
+				continue
+			}
+			if got := ctx.Input.Param("tenant"); got != tt.tenant {
+				t.Errorf("%s: tenant = %q, want %q", tt.url, got, tt.tenant)
+			}
+		}
+	}
+}
@@ -7,7 +7,6 @@ package router
 
 import (
 	"fmt"
-	"github.com/liuyongshuai/negoutils/comutils"
 	"github.com/liuyongshuai/thingo/context"
 	"regexp"
 	"strings"
@@ -16,71 +15,28 @@ import (
 //路由解析函数
 type RouterMatchFunc func(*context.ThingoContext, string, *ThingoRouterItem) *ThingoRouterItem
 
-/**
-匹配全路径路由
-	:arg	只配置数字类型
-	:arg:	可以匹配任意类型
-如：
-/ggtest/:id	匹配下面的URL，并把 id作为参数回传给controller层
-	/ggtest/6666
-	/ggtest/89999
-/ggtest/:name:	匹配下面的URL。并把name作为参数回传给controller层
-	/ggtest/wendao
-	/ggtest/abc
-	/ggtest/44444
-*/
-func matchPathInfoRouter(ctx *context.ThingoContext, uri string, rt *ThingoRouterItem) *ThingoRouterItem {
-	//请求的URL的切片
-	pathinfo := strings.Split(uri, "/")
-	//事先配置好的路由的切片，要和URL逐个比对，若有:arg、:arg:这样的还要替换
-	config := strings.Split(rt.Config, "/")
-	if len(pathinfo) > len(config) {
-		return nil
-	}
-	for key, val := range config {
-		//当前段以冒号“:”开头
-		if strings.HasPrefix(val, ":") {
-			if len(pathinfo) > key {
-				//非数字必须  :arg:，数字只能 :arg
-				if !comutils.IsAllNumber(pathinfo[key]) && !strings.HasSuffix(val, ":") {
-					return nil
-				}
-				//提取匹配上的参数名和参数值，并回填到Input对象里去
-				pk, pv := strings.Trim(val, ":"), pathinfo[key]
-				ctx.Input.SetParam(pk, pv)
-			}
-			//如果不是以冒号“:”开头，则请求URL的各段必须和配置的路由的各段完全相等才算匹配上
-		} else if len(pathinfo) <= key || pathinfo[key] != val {
-			return nil
-		}
-	}
-	return rt
-}
-
 /**
 匹配正则路由，直接用正则表达式去匹配请求的URL，并把捕获的参数回传到Param配置里,如
 config 为 `^ggtest/aid(\w+?)/cid(\d+)$`，Param 为 aid=$1&cid=$2
 则将请求中aid后面的字符串挑出来赋给aid，cid后面的字符串挑出来赋给cid
 */
 func matchRegexpRouter(ctx *context.ThingoContext, uri string, rt *ThingoRouterItem) *ThingoRouterItem {
-	//先判断请求的URLj否匹配给定的正则表达式
-	match, err := regexp.MatchString(rt.Config, uri)
-	if err != nil || !match {
+	//正则在添加路由时已经编译好了，自定义的路由项可能没有
+	reg := rt.reg
+	if reg == nil {
+		var err error
+		if reg, err = regexp.Compile(rt.Config); err != nil {
+			return nil
+		}
+	}
+	//从请求的URL里提取出被捕获的参数部分，0项为url，其他项即为正则捕获的参数
+	routeArgs := reg.FindStringSubmatch(uri)
+	if routeArgs == nil {
 		return nil
 	}
+	routeArgs = routeArgs[1:]
 	//要替换的参数配置，如a=$1&b=$2，1、2对应正则表达式里的捕获的参数
 	arg := rt.Param
-	reg := regexp.MustCompile(rt.Config)
-	//从请求的URL里提取出被捕获的参数部分，返回值为包含切片的切片，取第一个即为匹配的参数
-	tmpArg := reg.FindAllStringSubmatch(uri, -1)
-	routeArgs := []string{}
-	if len(tmpArg) > 0 {
-		routeArgs = tmpArg[0]
-	}
-	//匹配出来的数据，0项为url，其他项即为正则捕获的参数
-	if len(routeArgs) > 0 {
-		routeArgs = routeArgs[1:]
-	}
 	//开始遍历所有捕获的参数，并替换配置好的$1、$2等
 	for i, val := range routeArgs {
 		j := i + 1
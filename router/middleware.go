@@ -0,0 +1,61 @@
+/**
+ * @author      Liu Yongshuai<liuyongshuai@hotmail.com>
+ * @package     router
+ * @date        2026-10-17 17:36
+ */
+package router
+
+import (
+	"github.com/liuyongshuai/thingo/context"
+)
+
+/**
+中间件函数，调用next执行后面的中间件及控制层，不调用则中断本次请求，如：
+	func Timing(ctx *context.ThingoContext, next func()) {
+		start := time.Now()
+		next()
+		ctx.Output.AddHeader("X-Cost", time.Since(start).String())
+	}
+在next前后都可以修改ctx.Output里的状态码、头信息及body，也可以在里面recover
+*/
+type MiddlewareFunc func(ctx *context.ThingoContext, next func())
+
+//按顺序执行中间件，最后执行final
+func RunMiddlewares(ctx *context.ThingoContext, mws []MiddlewareFunc, final func()) {
+	i := 0
+	var next func()
+	next = func() {
+		if i < len(mws) {
+			mw := mws[i]
+			i++
+			mw(ctx, next)
+			return
+		}
+		//重复调用next时不会再次执行final
+		if i == len(mws) {
+			i++
+			final()
+		}
+	}
+	next()
+}
+
+/**
+把前后插件包装成一个中间件：
+	先依次执行before，期间ctx.Output.Started被置为true时直接中断，不再执行后面的
+	再执行next，最后依次执行after
+*/
+func HooksMiddleware(before, after []HookFunc) MiddlewareFunc {
+	return func(ctx *context.ThingoContext, next func()) {
+		for _, hk := range before {
+			hk(ctx)
+			if ctx.Output.Started == true {
+				return
+			}
+		}
+		next()
+		for _, hk := range after {
+			hk(ctx)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+/**
+ * @author      Liu Yongshuai<liuyongshuai@hotmail.com>
+ * @package     router
+ * @date        2026-10-17 15:02
+ */
+package router
+
+import (
+	"fmt"
+	"github.com/liuyongshuai/negoutils/comutils"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"sync"
+	"time"
+)
+
+//路由段的类型
+const (
+	segStatic   = iota //静态段，必须完全相等
+	segParam           //参数段，如 :id、:name:、:id<int>
+	segCatchAll        //通配段，如 *path，匹配剩下的所有段
+)
+
+//参数段的校验规则，key相同的参数段在树上共用同一个节点
+type paramCheck struct {
+	key      string            //规则的唯一标识
+	priority int               //匹配的优先级，越小越先尝试
+	fn       func(string) bool //校验函数
+}
+
+var (
+	//只能匹配数字，:arg、:arg<int>
+	checkNumber = &paramCheck{key: "int", priority: 10, fn: comutils.IsAllNumber}
+	//可以匹配任意值，:arg:、:arg<string>
+	checkAny = &paramCheck{key: "string", priority: 1000, fn: func(string) bool { return true }}
+
+	//uuid的格式
+	uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+	//所有有名称的参数类型，如 :id<int> 里的int
+	paramChecks = map[string]*paramCheck{
+		"int":    checkNumber,
+		"string": checkAny,
+		"uuid":   {key: "uuid", priority: 20, fn: uuidRegexp.MatchString},
+		"date": {key: "date", priority: 20, fn: func(s string) bool {
+			_, err := time.Parse("2006-01-02", s)
+			return err == nil
+		}},
+		"alpha": {key: "alpha", priority: 30, fn: func(s string) bool {
+			return s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ") == ""
+		}},
+	}
+	paramChecksLock sync.RWMutex
+)
+
+//正则类型的参数排在内置类型之后、任意类型之前，相互之间按添加的顺序
+const regexpCheckPriority = 50
+
+/**
+添加一种有名称的参数类型，在路由里以 :arg<name> 的形式使用，需在添加路由前调用
+priority越小越先尝试，内置类型int为10、uuid/date为20、alpha为30、正则为50、string为1000
+*/
+func AddParamType(name string, priority int, fn func(string) bool) {
+	paramChecksLock.Lock()
+	defer paramChecksLock.Unlock()
+	paramChecks[name] = &paramCheck{key: name, priority: priority, fn: fn}
+}
+
+//和内置类型等价的正则，按规范化后的写法索引，如 \d+、[0-9]+ 都当作int
+var regexpParamTypes = map[string]string{
+	canonicalRegexp(`[0-9]+`):    "int",
+	canonicalRegexp(`[a-zA-Z]+`): "alpha",
+}
+
+//把正则规范化，写法不同但等价的正则得到同样的结果，如 \d{1,} 和 [0-9]+
+func canonicalRegexp(expr string) string {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return expr
+	}
+	return re.Simplify().String()
+}
+
+//只有字母、数字、下划线的约束当作类型名称
+var paramTypeNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+/**
+按 <...> 里的内容提取校验规则，不是已知类型的当作正则处理
+只有字母、数字、下划线的当作类型名称，没有注册过时返回错误，避免 :id<uiid> 这样的笔误被当成正则，
+要按字面匹配单词时写成 :v<(abc)>
+正则按规范化后的写法作为key，和内置类型等价的直接用内置类型，这样添加路由时才能发现冲突
+*/
+func lookupParamCheck(constraint string) (*paramCheck, error) {
+	paramChecksLock.RLock()
+	pc, ok := paramChecks[constraint]
+	paramChecksLock.RUnlock()
+	if ok {
+		return pc, nil
+	}
+	if paramTypeNameRegexp.MatchString(constraint) {
+		return nil, fmt.Errorf("unknown param type %q", constraint)
+	}
+	reg, err := regexp.Compile("^(?:" + constraint + ")$")
+	if err != nil {
+		return nil, err
+	}
+	canonical := canonicalRegexp(constraint)
+	if name, ok := regexpParamTypes[canonical]; ok {
+		paramChecksLock.RLock()
+		pc, ok = paramChecks[name]
+		paramChecksLock.RUnlock()
+		if ok {
+			return pc, nil
+		}
+	}
+	return &paramCheck{key: "regexp:" + canonical, priority: regexpCheckPriority, fn: reg.MatchString}, nil
+}
+
+//解析后的单个路由段
+type routeSegment struct {
+	kind     int         //段的类型
+	value    string      //静态段的内容或参数名称
+	check    *paramCheck //参数段的校验规则
+	optional bool        //是否可以省略，只能出现在末尾
+	legacy   bool        //是否为以前的 :arg、:arg: 写法
+}
+
+/**
+解析全路径路由的配置，首尾的“/”会被去掉，支持以下几种段：
+	abc		静态段，必须完全相等
+	:id		只匹配数字，同 :id<int>
+	:name:	匹配任意值，同 :name<string>
+	:id<int>、:uid<uuid>、:day<date>、:word<alpha>	内置类型
+	:slug<[a-z-]+>	按正则匹配整段
+	:page<int>?		末尾加“?”表示可以省略，只能出现在末尾
+	*path	匹配剩下的所有段，只能是最后一段
+
+兼容以前的写法，末尾连续的 :arg、:arg: 都是可以省略的
+*/
+func parsePathPattern(config string) ([]routeSegment, error) {
+	config = strings.Trim(config, "/")
+	if config == "" {
+		return nil, nil
+	}
+	parts := strings.Split(config, "/")
+	segs := make([]routeSegment, 0, len(parts))
+	for i, p := range parts {
+		switch {
+		case strings.HasPrefix(p, "*"):
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("catch-all segment %q must be the last one in %q", p, config)
+			}
+			segs = append(segs, routeSegment{kind: segCatchAll, value: p[1:]})
+		case strings.HasPrefix(p, ":"):
+			seg, err := parseParamSegment(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid segment %q in %q: %v", p, config, err)
+			}
+			segs = append(segs, seg)
+		default:
+			segs = append(segs, routeSegment{kind: segStatic, value: p})
+		}
+	}
+
+	//从后往前看，后面全是可省略的段时，以前写法的参数段也可以省略
+	allOptional := true
+	for i := len(segs) - 1; i >= 0; i-- {
+		seg := &segs[i]
+		if seg.legacy && allOptional {
+			seg.optional = true
+		}
+		if seg.optional && !allOptional {
+			return nil, fmt.Errorf("optional segment :%s must be at the end of %q", seg.value, config)
+		}
+		if !seg.optional && seg.kind != segCatchAll {
+			allOptional = false
+		}
+	}
+	return segs, nil
+}
+
+//解析单个参数段
+func parseParamSegment(p string) (routeSegment, error) {
+	seg := routeSegment{kind: segParam}
+	p = p[1:]
+	if strings.HasSuffix(p, "?") {
+		seg.optional = true
+		p = p[:len(p)-1]
+	}
+	if pos := strings.Index(p, "<"); pos >= 0 {
+		if !strings.HasSuffix(p, ">") {
+			return seg, fmt.Errorf("missing '>'")
+		}
+		pc, err := lookupParamCheck(p[pos+1 : len(p)-1])
+		if err != nil {
+			return seg, err
+		}
+		seg.value = p[:pos]
+		seg.check = pc
+	} else if strings.HasSuffix(p, ":") {
+		seg.value = p[:len(p)-1]
+		seg.check = checkAny
+		seg.legacy = !seg.optional
+	} else {
+		seg.value = p
+		seg.check = checkNumber
+		seg.legacy = !seg.optional
+	}
+	if seg.value == "" {
+		return seg, fmt.Errorf("empty param name")
+	}
+	return seg, nil
+}
+
+//提取所有的参数名称，按出现的顺序
+func segmentParamNames(segs []routeSegment) []string {
+	var names []string
+	for _, s := range segs {
+		if s.kind != segStatic {
+			names = append(names, s.value)
+		}
+	}
+	return names
+}
+
+//路由在树上实际挂载的各种形式：完整的一条，以及依次省略末尾可省略段后的，通配段本身就可以匹配空值
+func segmentVariants(segs []routeSegment) [][]routeSegment {
+	ret := [][]routeSegment{segs}
+	end := len(segs)
+	if end > 0 && segs[end-1].kind == segCatchAll {
+		end--
+	}
+	for i := end - 1; i >= 0 && segs[i].optional; i-- {
+		ret = append(ret, segs[:i])
+	}
+	return ret
+}
@@ -1,6 +1,13 @@
 package router
 
-import "reflect"
+import (
+	"fmt"
+	"github.com/liuyongshuai/thingo/context"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+)
 
 //路由类型
 const (
@@ -9,17 +16,144 @@ const (
 	RouterTypeRegexp   //正则匹配
 )
 
-//单个路由结构体
+//函数类型的处理句柄
+type ThingoHandlerFunc func(ctx *context.ThingoContext)
+
+//单个路由结构体，Controller、Handler二选一
 type ThingoRouterItem struct {
-	Type           int          //路由类型
-	Config         string       //相关的配置
-	Controller     interface{}  //所引用的控制层
-	ControllerType reflect.Type //控制层的类型
-	Param          string       //额外的参数
+	Type           int                //路由类型
+	Config         string             //相关的配置
+	Controller     interface{}        //所引用的控制层
+	ControllerType reflect.Type       //控制层的类型
+	Handler        interface{}        //处理函数，可以是func(*context.ThingoContext)、http.Handler、http.HandlerFunc
+	Param          string             //额外的参数
+	Methods        []string           //允许的请求方法，如GET、POST，为空时不限制
+	Host           string             //限定的域名，如 :tenant.example.com，为空时不限制
+	Group          *ThingoRouterGroup //所属的路由分组
+	Name           string             //路由名称，用来反向生成URL
+	Middlewares    []MiddlewareFunc   //只对本路由生效的中间件，在分组的中间件之后执行
+	Produces       []string           //可输出的类型，如“application/json”，为空时不限制，Render时按它协商
+
+	segments   []routeSegment    //全路径路由解析后的各段
+	paramNames []string          //全路径路由里的参数名称
+	reg        *regexp.Regexp    //正则路由编译好的正则
+	host       *hostPattern      //解析后的域名规则
+	handle     ThingoHandlerFunc //由Handler转换成的处理函数
 }
 
 //要缓存的路由
 type ThingoRouterCache struct {
-	R *ThingoRouterItem //匹配的路由项
-	F RouterMatchFunc   //所要用的处理函数
+	R      *ThingoRouterItem   //匹配的路由项
+	F      RouterMatchFunc     //所要用的处理函数，为nil时直接回填Params
+	Params []ThingoRouterParam //前缀树匹配时捕获的参数
+}
+
+//将Handler统一转换为函数类型的处理句柄
+func (r *ThingoRouterItem) prepareHandler() error {
+	r.handle = nil
+	r.ControllerType = nil
+	if r.Handler == nil {
+		if r.Controller == nil {
+			return fmt.Errorf("router %q: controller or handler required", r.Config)
+		}
+		reflectVal := reflect.ValueOf(r.Controller)
+		r.ControllerType = reflect.Indirect(reflectVal).Type()
+		return nil
+	}
+	switch h := r.Handler.(type) {
+	case ThingoHandlerFunc:
+		r.handle = h
+	case func(*context.ThingoContext):
+		r.handle = h
+	case http.Handler:
+		r.handle = wrapHTTPHandler(h)
+	case func(http.ResponseWriter, *http.Request):
+		r.handle = wrapHTTPHandler(http.HandlerFunc(h))
+	default:
+		return fmt.Errorf("router %q: unsupported handler type %T", r.Config, r.Handler)
+	}
+	return nil
+}
+
+//标准库的http.Handler，写入的内容先暂存到Output里，和控制层一样统一输出
+func wrapHTTPHandler(h http.Handler) ThingoHandlerFunc {
+	return func(ctx *context.ThingoContext) {
+		h.ServeHTTP(ctx.Output.ResponseWriter(), ctx.Request)
+	}
+}
+
+//函数类型的处理句柄，配置的是控制层时返回nil
+func (r *ThingoRouterItem) HandleFunc() ThingoHandlerFunc {
+	return r.handle
+}
+
+//规范化请求方法列表，统一转为大写并去重
+func (r *ThingoRouterItem) normalizeMethods() {
+	if len(r.Methods) == 0 {
+		return
+	}
+	var ms []string
+	seen := make(map[string]bool)
+	for _, m := range r.Methods {
+		m = strings.ToUpper(strings.TrimSpace(m))
+		if m == "" || seen[m] {
+			continue
+		}
+		seen[m] = true
+		ms = append(ms, m)
+	}
+	r.Methods = ms
+}
+
+//是否为不带参数的静态路由
+func (r *ThingoRouterItem) isStatic() bool {
+	return r.Type == RouterTypePathInfo && len(r.paramNames) == 0
+}
+
+//是否匹配请求的域名，域名已去掉端口并转为小写
+func (r *ThingoRouterItem) matchHost(host string) bool {
+	return r.host == nil || r.host.match(host)
+}
+
+//提取域名里的参数
+func (r *ThingoRouterItem) hostParams(host string) []ThingoRouterParam {
+	if r.host == nil || r.host.params == 0 {
+		return nil
+	}
+	params, _ := r.host.extract(host, true)
+	return params
+}
+
+//两条路由的域名及请求方法是否有重叠，挂在同一个节点上时就是冲突的
+func (r *ThingoRouterItem) overlaps(o *ThingoRouterItem) bool {
+	if strings.ToLower(r.Host) != strings.ToLower(o.Host) {
+		return false
+	}
+	if len(r.Methods) == 0 || len(o.Methods) == 0 {
+		return true
+	}
+	for _, m := range r.Methods {
+		for _, om := range o.Methods {
+			if m == om {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//是否允许某个请求方法，HEAD请求可以使用GET的路由
+func (r *ThingoRouterItem) AllowMethod(method string) bool {
+	if len(r.Methods) == 0 {
+		return true
+	}
+	for _, m := range r.Methods {
+		if m == method {
+			return true
+		}
+	}
+	if method == http.MethodHead {
+		return r.AllowMethod(http.MethodGet)
+	}
+	return false
 }
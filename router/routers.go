@@ -6,48 +6,119 @@
 package router
 
 import (
+	"fmt"
 	"github.com/liuyongshuai/thingo/context"
+	"net"
 	"net/http"
-	"net/url"
-	"reflect"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 //返回一个路由列表信息
 func NewThingoRouterList() *ThingoRouterList {
 	ret := &ThingoRouterList{
-		RCache: make(map[string]ThingoRouterCache),
+		RCache: NewThingoRouteCache(DefaultRouteCacheSize, false),
 		MFunc:  make(map[int]RouterMatchFunc),
 		Mutex:  new(sync.RWMutex),
+		names:  make(map[string]*ThingoRouterItem),
+		check:  newRouteNode(),
 	}
-	ret.AddMatchFunc(RouterTypePathInfo, matchPathInfoRouter).
-		AddMatchFunc(RouterTypeRegexp, matchRegexpRouter)
+	ret.AddMatchFunc(RouterTypeRegexp, matchRegexpRouter)
 	return ret
 }
 
 //一堆路由列表，带缓存
 type ThingoRouterList struct {
-	RList  []*ThingoRouterItem          //所有的路由列表信息
-	RCache map[string]ThingoRouterCache //已经匹配过的缓存起来
-	MFunc  map[int]RouterMatchFunc      //各种类型的处理函数
+	RList  []*ThingoRouterItem     //所有的路由列表信息
+	RCache *ThingoRouteCache       //已经匹配过的缓存起来
+	MFunc  map[int]RouterMatchFunc //各种类型的处理函数
 	Mutex  *sync.RWMutex
+	table  atomic.Value                 //当前生效的路由表，类型为*routeTable，为nil时表示需要重建
+	names  map[string]*ThingoRouterItem //有名称的路由，用来反向生成URL
+	check  *routeNode                   //添加路由时用来检查冲突的前缀树
+
+	defaultHost string //默认域名，请求的域名匹配不上任何路由时按它来匹配
+}
+
+//由RList构建出来的只读路由表，匹配时不用加锁
+type routeTable struct {
+	tree   *routeNode                //全路径路由的前缀树
+	others []*ThingoRouterItem       //其他类型的路由，按添加顺序逐个匹配
+	order  map[*ThingoRouterItem]int //每条路由添加的顺序
 }
 
-//添加处理函数
+/**
+添加处理函数
+全路径路由默认用前缀树匹配，若给RouterTypePathInfo指定了处理函数，则改用该函数逐个匹配
+*/
 func (rs *ThingoRouterList) AddMatchFunc(t int, fn RouterMatchFunc) *ThingoRouterList {
+	rs.Mutex.Lock()
+	defer rs.Mutex.Unlock()
 	rs.MFunc[t] = fn
+	rs.table.Store((*routeTable)(nil))
+	rs.RCache.Clear()
+	return rs
+}
+
+/**
+设置路由缓存，需在开始运行前调用
+	size：最多缓存的条数，小于等于0表示不缓存
+	staticOnly：只缓存不带参数的路由，避免 /item/:id 这类路由把缓存占满
+*/
+func (rs *ThingoRouterList) SetCache(size int, staticOnly bool) *ThingoRouterList {
+	rs.Mutex.Lock()
+	defer rs.Mutex.Unlock()
+	rs.RCache = NewThingoRouteCache(size, staticOnly)
+	return rs
+}
+
+//设置默认域名，请求的域名匹配不上任何路由时按它来匹配，需在开始运行前调用
+func (rs *ThingoRouterList) SetDefaultHost(host string) *ThingoRouterList {
+	host = strings.ToLower(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	rs.defaultHost = host
+	rs.RCache.Clear()
 	return rs
 }
 
-//添加路由信息
+//路由缓存的统计信息
+func (rs *ThingoRouterList) CacheStats() ThingoRouteCacheStats {
+	return rs.RCache.Stats()
+}
+
+//添加路由信息，配置有误时直接panic
 func (rs *ThingoRouterList) AddRouter(r *ThingoRouterItem) *ThingoRouterList {
 	if r == nil {
 		return rs
 	}
-	reflectVal := reflect.ValueOf(r.Controller)
-	r.ControllerType = reflect.Indirect(reflectVal).Type()
+	if err := r.prepare(); err != nil {
+		panic(err)
+	}
+	rs.Mutex.Lock()
+	defer rs.Mutex.Unlock()
+	if r.Name != "" {
+		if _, ok := rs.names[r.Name]; ok {
+			panic(fmt.Errorf("duplicate router name %q", r.Name))
+		}
+	}
+	//同样的路径、域名、请求方法只能有一条路由，不再默认先添加的优先
+	if r.Type == RouterTypePathInfo {
+		if old := rs.check.findConflict(r.segments, r); old != nil {
+			panic(fmt.Errorf("router %q conflicts with existing router %q", r.Config, old.Config))
+		}
+		rs.check.addRoute(r.segments, r)
+	}
+	if r.Name != "" {
+		rs.names[r.Name] = r
+	}
 	rs.RList = append(rs.RList, r)
+	rs.table.Store((*routeTable)(nil))
+	rs.RCache.Clear()
 	return rs
 }
 
@@ -57,57 +128,189 @@ func (rs *ThingoRouterList) AddRouters(r ...*ThingoRouterItem) *ThingoRouterList
 		return rs
 	}
 	for _, i := range r {
-		if i != nil {
-			reflectVal := reflect.ValueOf(i.Controller)
-			i.ControllerType = reflect.Indirect(reflectVal).Type()
-			rs.RList = append(rs.RList, i)
-		}
+		rs.AddRouter(i)
 	}
 	return rs
 }
 
+//注册路由前的预处理：提取控制层类型或处理函数、解析路由配置、编译正则
+func (r *ThingoRouterItem) prepare() error {
+	if err := r.prepareHandler(); err != nil {
+		return err
+	}
+	r.normalizeMethods()
+	switch r.Type {
+	case RouterTypePathInfo:
+		segs, err := parsePathPattern(r.Config)
+		if err != nil {
+			return err
+		}
+		r.segments = segs
+		r.paramNames = segmentParamNames(segs)
+	case RouterTypeRegexp:
+		reg, err := regexp.Compile(r.Config)
+		if err != nil {
+			return fmt.Errorf("invalid regexp router %q: %v", r.Config, err)
+		}
+		r.reg = reg
+	}
+	r.host = nil
+	if r.Host != "" {
+		hp, err := parseHostPattern(r.Host)
+		if err != nil {
+			return err
+		}
+		r.host = hp
+	}
+	return nil
+}
+
+//提取当前的路由表，没有时重新构建
+func (rs *ThingoRouterList) loadTable() *routeTable {
+	if t, _ := rs.table.Load().(*routeTable); t != nil {
+		return t
+	}
+	rs.Mutex.Lock()
+	defer rs.Mutex.Unlock()
+	if t, _ := rs.table.Load().(*routeTable); t != nil {
+		return t
+	}
+	t := &routeTable{tree: newRouteNode(), order: make(map[*ThingoRouterItem]int, len(rs.RList))}
+	_, customPathInfo := rs.MFunc[RouterTypePathInfo]
+	for i, r := range rs.RList {
+		t.order[r] = i
+		if r.Type == RouterTypePathInfo && !customPathInfo {
+			t.tree.addRoute(r.segments, r)
+		} else {
+			t.others = append(t.others, r)
+		}
+	}
+	rs.table.Store(t)
+	return t
+}
+
 //开始匹配路由
 func (rs *ThingoRouterList) Match(ctx *context.ThingoContext, req *http.Request) *ThingoRouterItem {
-	if len(rs.RList) == 0 {
-		return nil
+	router, _ := rs.Lookup(ctx, req)
+	return router
+}
+
+/**
+按请求的路径及方法匹配路由
+全路径路由之间按前缀树的优先级，静态段优先于参数段；和正则等其他类型的路由都能匹配上时，先添加的优先
+当路径能匹配上但请求方法不被允许时，返回nil及该路径所允许的方法列表，
+列表里会自动加上OPTIONS，有GET时也会加上HEAD
+*/
+func (rs *ThingoRouterList) Lookup(ctx *context.ThingoContext, req *http.Request) (*ThingoRouterItem, []string) {
+	table := rs.loadTable()
+	if table.tree.isEmpty() && len(table.others) == 0 {
+		return nil, nil
 	}
 	//提取请求的URI
 	path := req.URL.Path
 	path = strings.Trim(path, "/")
 
-	//解析请求的URI，提取参数，放到上下文环境里
-	urlInfo, _ := url.Parse(req.RequestURI)
-	vals := urlInfo.Query()
-	for k, vs := range vals {
-		if len(vs) > 0 {
-			ctx.Input.SetParam(k, vs[0])
-		} else {
-			ctx.Input.SetParam(k, "")
+	//先提取缓存里有没有，缓存的key要带上请求方法及域名
+	method := req.Method
+	host := requestHost(ctx, req)
+	if host == "" {
+		host = rs.defaultHost
+	}
+	cacheKey := method + " " + host + " " + path
+	if rc, ok := rs.RCache.Get(cacheKey); ok {
+		if rc.F == nil {
+			for _, p := range rc.Params {
+				ctx.Input.SetParam(p.Key, p.Value)
+			}
+			rs.setHostParams(ctx, rc.R, host)
+			return rc.R, nil
+		}
+		if rter := rc.F(ctx, path, rc.R); rter != nil {
+			rs.setHostParams(ctx, rter, host)
+			return rter, nil
 		}
 	}
 
-	//先提取缓存里有没有
-	if rc, ok := rs.RCache[path]; ok {
-		router := rc.R
-		fn := rc.F
-		rter := fn(ctx, path, router)
-		if rter != nil {
-			return rter
-		}
+	//按请求的域名匹配不上时，再按默认域名匹配一次
+	router, allowed := rs.lookupHost(ctx, table, path, method, host, cacheKey)
+	if router == nil && len(allowed) == 0 && rs.defaultHost != "" && host != rs.defaultHost {
+		router, allowed = rs.lookupHost(ctx, table, path, method, rs.defaultHost, cacheKey)
+	}
+	if router != nil {
+		rs.setHostParams(ctx, router, host)
+		return router, nil
 	}
+	if len(allowed) == 0 {
+		return nil, nil
+	}
+	if allowed[http.MethodGet] {
+		allowed[http.MethodHead] = true
+	}
+	allowed[http.MethodOptions] = true
+	var ret []string
+	for m := range allowed {
+		ret = append(ret, m)
+	}
+	sort.Strings(ret)
+	return nil, ret
+}
 
-	//开始匹配路由信息
-	rs.Mutex.Lock()
-	defer rs.Mutex.Unlock()
-	var router *ThingoRouterItem = nil
-	for _, rinfo := range rs.RList {
+//按指定的域名匹配路由，匹配上时写入缓存，否则返回该路径所允许的请求方法
+func (rs *ThingoRouterList) lookupHost(ctx *context.ThingoContext, table *routeTable, path, method, host, cacheKey string) (*ThingoRouterItem, map[string]bool) {
+	//先在前缀树里找，再逐个匹配其他类型的路由，只有比前缀树里匹配上的先添加的才优先
+	q := &routeQuery{method: method, host: host, allowed: make(map[string]bool)}
+	treeRouter, params := table.tree.find(path, q)
+	for _, rinfo := range table.others {
+		if treeRouter != nil && table.order[rinfo] > table.order[treeRouter] {
+			break
+		}
+		if !rinfo.matchHost(host) || !rinfo.AllowMethod(method) {
+			continue
+		}
 		if fn, ok := rs.MFunc[rinfo.Type]; ok {
-			router = fn(ctx, path, rinfo)
-			if router != nil {
-				rs.RCache[path] = ThingoRouterCache{F: fn, R: router}
-				return router
+			if router := fn(ctx, path, rinfo); router != nil {
+				rs.RCache.Set(cacheKey, ThingoRouterCache{F: fn, R: router})
+				return router, nil
 			}
 		}
 	}
-	return nil
+	if treeRouter != nil {
+		for _, p := range params {
+			ctx.Input.SetParam(p.Key, p.Value)
+		}
+		rs.RCache.Set(cacheKey, ThingoRouterCache{R: treeRouter, Params: params})
+		return treeRouter, nil
+	}
+
+	//没有匹配上时，再看看是不是请求方法不对
+	allowed := q.allowed
+	for _, rinfo := range table.others {
+		if !rinfo.matchHost(host) || rinfo.AllowMethod(method) {
+			continue
+		}
+		if fn, ok := rs.MFunc[rinfo.Type]; ok && fn(ctx, path, rinfo) != nil {
+			for _, m := range rinfo.Methods {
+				allowed[m] = true
+			}
+		}
+	}
+	return nil, allowed
+}
+
+//把域名里的参数回填到Input对象里，请求的域名匹配不上时说明用的是默认域名
+func (rs *ThingoRouterList) setHostParams(ctx *context.ThingoContext, r *ThingoRouterItem, host string) {
+	if !r.matchHost(host) {
+		host = rs.defaultHost
+	}
+	for _, p := range r.hostParams(host) {
+		ctx.Input.SetParam(p.Key, p.Value)
+	}
+}
+
+//提取请求的域名，去掉端口并转为小写，经过信任的代理时取代理转发过来的
+func requestHost(ctx *context.ThingoContext, req *http.Request) string {
+	if req.Host == "" {
+		return ""
+	}
+	return strings.ToLower(ctx.Input.Host())
 }
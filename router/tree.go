@@ -0,0 +1,277 @@
+/**
+ * @author      Liu Yongshuai<liuyongshuai@hotmail.com>
+ * @package     router
+ * @date        2026-10-17 10:12
+ */
+package router
+
+import (
+	"strings"
+)
+
+//匹配时捕获的单个参数
+type ThingoRouterParam struct {
+	Key   string
+	Value string
+}
+
+//前缀树的节点，连续的静态段会被压缩到同一个节点上
+type routeNode struct {
+	kind     int                   //节点类型，同路由段类型
+	prefix   []string              //静态节点压缩后的多个路径段
+	check    *paramCheck           //参数节点的校验规则
+	statics  map[string]*routeNode //静态子节点，按子节点的第一段索引
+	params   []*routeNode          //参数子节点，按优先级排列
+	catchAll *routeNode            //通配子节点
+	routes   []*ThingoRouterItem   //在此节点结束的路由，按添加顺序
+}
+
+//新建一个前缀树的根节点
+func newRouteNode() *routeNode {
+	return &routeNode{kind: segStatic}
+}
+
+//是否一条路由都没有
+func (n *routeNode) isEmpty() bool {
+	return len(n.statics) == 0 && len(n.params) == 0 && n.catchAll == nil && len(n.routes) == 0
+}
+
+//添加一条路由，末尾可省略的段会让路由同时挂在多个节点上
+func (n *routeNode) addRoute(segs []routeSegment, r *ThingoRouterItem) {
+	for _, v := range segmentVariants(segs) {
+		n.insert(v, r)
+	}
+}
+
+/**
+检查添加路由后是否会和已有的路由冲突，有冲突时返回已有的那条路由
+参数段只有校验规则相同时才算冲突，等价的正则及和int、alpha等价的正则都当作同一个规则；
+不同的规则即使能匹配同样的值也不算冲突，如 :id<int> 和 :v<[0-9a-f]+>，匹配时按优先级依次尝试，
+优先级高的能匹配上时后面的就匹配不到了
+*/
+func (n *routeNode) findConflict(segs []routeSegment, r *ThingoRouterItem) *ThingoRouterItem {
+	for _, v := range segmentVariants(segs) {
+		if node := n.walk(v); node != nil {
+			for _, old := range node.routes {
+				if old != r && old.overlaps(r) {
+					return old
+				}
+			}
+		}
+	}
+	return nil
+}
+
+//按路由段找到已存在的结束节点，不存在时返回nil
+func (n *routeNode) walk(segs []routeSegment) *routeNode {
+	for len(segs) > 0 {
+		seg := segs[0]
+		switch seg.kind {
+		case segStatic:
+			child, ok := n.statics[seg.value]
+			if !ok || len(segs) < len(child.prefix) {
+				return nil
+			}
+			for i, p := range child.prefix {
+				if segs[i].kind != segStatic || segs[i].value != p {
+					return nil
+				}
+			}
+			n, segs = child, segs[len(child.prefix):]
+		case segParam:
+			var next *routeNode
+			for _, p := range n.params {
+				if p.check.key == seg.check.key {
+					next = p
+					break
+				}
+			}
+			if next == nil {
+				return nil
+			}
+			n, segs = next, segs[1:]
+		case segCatchAll:
+			return n.catchAll
+		}
+	}
+	return n
+}
+
+//插入一条路由
+func (n *routeNode) insert(segs []routeSegment, r *ThingoRouterItem) {
+	if len(segs) == 0 {
+		n.routes = append(n.routes, r)
+		return
+	}
+	seg := segs[0]
+	switch seg.kind {
+	case segStatic:
+		//把连续的静态段收集起来
+		run := []string{}
+		for _, s := range segs {
+			if s.kind != segStatic {
+				break
+			}
+			run = append(run, s.value)
+		}
+		if n.statics == nil {
+			n.statics = make(map[string]*routeNode)
+		}
+		child, ok := n.statics[run[0]]
+		if !ok {
+			child = &routeNode{kind: segStatic, prefix: run}
+			n.statics[run[0]] = child
+			child.insert(segs[len(run):], r)
+			return
+		}
+		k := 0
+		for k < len(run) && k < len(child.prefix) && run[k] == child.prefix[k] {
+			k++
+		}
+		if k < len(child.prefix) {
+			child.split(k)
+		}
+		child.insert(segs[k:], r)
+	case segParam:
+		var child *routeNode
+		for _, p := range n.params {
+			if p.check.key == seg.check.key {
+				child = p
+				break
+			}
+		}
+		if child == nil {
+			child = &routeNode{kind: segParam, check: seg.check}
+			pos := len(n.params)
+			for i, p := range n.params {
+				if seg.check.priority < p.check.priority {
+					pos = i
+					break
+				}
+			}
+			n.params = append(n.params, nil)
+			copy(n.params[pos+1:], n.params[pos:])
+			n.params[pos] = child
+		}
+		child.insert(segs[1:], r)
+	case segCatchAll:
+		if n.catchAll == nil {
+			n.catchAll = &routeNode{kind: segCatchAll}
+		}
+		n.catchAll.routes = append(n.catchAll.routes, r)
+	}
+}
+
+//将静态节点在第k段处拆成两个节点
+func (n *routeNode) split(k int) {
+	tail := &routeNode{
+		kind:     segStatic,
+		prefix:   n.prefix[k:],
+		statics:  n.statics,
+		params:   n.params,
+		catchAll: n.catchAll,
+		routes:   n.routes,
+	}
+	n.prefix = n.prefix[:k]
+	n.statics = map[string]*routeNode{tail.prefix[0]: tail}
+	n.params = nil
+	n.catchAll = nil
+	n.routes = nil
+}
+
+//一次查找的上下文
+type routeQuery struct {
+	method  string          //请求方法
+	host    string          //请求的域名，不带端口
+	values  []string        //沿途捕获的参数值
+	allowed map[string]bool //路径匹配上但方法不对的路由所允许的方法
+}
+
+//从节点上的路由里挑出第一个匹配域名且允许当前请求方法的
+func (q *routeQuery) pick(routes []*ThingoRouterItem) *ThingoRouterItem {
+	//限定了域名的路由优先于不限域名的
+	for rank := 0; rank <= 2; rank++ {
+		for _, r := range routes {
+			if r.host.rank() == rank && r.matchHost(q.host) && r.AllowMethod(q.method) {
+				return r
+			}
+		}
+	}
+	for _, r := range routes {
+		if !r.matchHost(q.host) {
+			continue
+		}
+		for _, m := range r.Methods {
+			q.allowed[m] = true
+		}
+	}
+	return nil
+}
+
+//查找路由，静态段优先，其次参数段，最后通配段，匹配不上时回溯
+func (n *routeNode) lookup(segs []string, q *routeQuery) *ThingoRouterItem {
+	if len(segs) == 0 {
+		if r := q.pick(n.routes); r != nil {
+			return r
+		}
+		if n.catchAll != nil {
+			q.values = append(q.values, "")
+			if r := q.pick(n.catchAll.routes); r != nil {
+				return r
+			}
+			q.values = q.values[:len(q.values)-1]
+		}
+		return nil
+	}
+	if child, ok := n.statics[segs[0]]; ok && len(segs) >= len(child.prefix) {
+		match := true
+		for i, p := range child.prefix {
+			if segs[i] != p {
+				match = false
+				break
+			}
+		}
+		if match {
+			if r := child.lookup(segs[len(child.prefix):], q); r != nil {
+				return r
+			}
+		}
+	}
+	for _, child := range n.params {
+		if !child.check.fn(segs[0]) {
+			continue
+		}
+		q.values = append(q.values, segs[0])
+		if r := child.lookup(segs[1:], q); r != nil {
+			return r
+		}
+		q.values = q.values[:len(q.values)-1]
+	}
+	if n.catchAll != nil {
+		q.values = append(q.values, strings.Join(segs, "/"))
+		if r := q.pick(n.catchAll.routes); r != nil {
+			return r
+		}
+		q.values = q.values[:len(q.values)-1]
+	}
+	return nil
+}
+
+//按请求的路径查找路由，返回匹配的路由及捕获的参数
+func (n *routeNode) find(path string, q *routeQuery) (*ThingoRouterItem, []ThingoRouterParam) {
+	var segs []string
+	if path != "" {
+		segs = strings.Split(path, "/")
+	}
+	r := n.lookup(segs, q)
+	if r == nil {
+		return nil, nil
+	}
+	var params []ThingoRouterParam
+	for i, v := range q.values {
+		if i < len(r.paramNames) {
+			params = append(params, ThingoRouterParam{Key: r.paramNames[i], Value: v})
+		}
+	}
+	return r, params
+}
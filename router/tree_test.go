@@ -0,0 +1,320 @@
+/**
+ * @author      Liu Yongshuai<liuyongshuai@hotmail.com>
+ * @package     router
+ * @date        2026-10-18 10:40
+ */
+package router
+
+import (
+	"fmt"
+	"github.com/liuyongshuai/thingo/context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func noopHandler(*context.ThingoContext) {}
+
+//新建一个路由列表，每个配置为“[方法 ]路径”
+func newTestRouterList(configs ...string) *ThingoRouterList {
+	rs := NewThingoRouterList()
+	for _, c := range configs {
+		item := &ThingoRouterItem{Type: RouterTypePathInfo, Handler: ThingoHandlerFunc(noopHandler)}
+		if pos := strings.Index(c, " "); pos > 0 {
+			item.Methods = []string{c[:pos]}
+			c = c[pos+1:]
+		}
+		item.Config = c
+		rs.AddRouter(item)
+	}
+	return rs
+}
+
+//新建一个请求的上下文
+func newTestContext(method, path string) *context.ThingoContext {
+	req := httptest.NewRequest(method, path, nil)
+	var rw http.ResponseWriter = httptest.NewRecorder()
+	ctx := context.NewThingoContext()
+	ctx.Reset(&rw, req)
+	return ctx
+}
+
+func init() {
+	//测试用的参数类型，优先级比int高
+	AddParamType("hex", 5, func(s string) bool {
+		return s != "" && strings.Trim(s, "0123456789abcdef") == ""
+	})
+}
+
+func TestTreeLookup(t *testing.T) {
+	rs := newTestRouterList(
+		"/",
+		"/user/new",
+		"/user/:name<string>",
+		"/user/:id<int>/posts",
+		"/files/:id<int>",
+		"/files/*path",
+		"/a/:x<string>/c",
+		"/a/b/d",
+		"/list/:page<int>?",
+		"/item/:id",
+		"/tag/:id/:name:",
+		"/t/:n<int>",
+		"/t/:d<date>",
+		"/t/:w<alpha>",
+		"/u/:uid<uuid>",
+		"/slug/:s<[a-z-]+>",
+		"/h/:v<hex>",
+		"/h/:n<int>/x",
+		"/static/*rest",
+		"GET /m/get",
+		"POST /m/post",
+	)
+	tests := []struct {
+		method string
+		path   string
+		want   string            //匹配上的路由配置，为空表示匹配不上
+		params map[string]string //捕获的参数
+	}{
+		{"GET", "/", "/", nil},
+		//静态段优先于参数段
+		{"GET", "/user/new", "/user/new", nil},
+		{"GET", "/user/bob", "/user/:name<string>", map[string]string{"name": "bob"}},
+		{"GET", "/user/12/posts", "/user/:id<int>/posts", map[string]string{"id": "12"}},
+		{"GET", "/user/bob/posts", "", nil},
+		//参数段优先于通配段
+		{"GET", "/files/12", "/files/:id<int>", map[string]string{"id": "12"}},
+		{"GET", "/files/a/b.txt", "/files/*path", map[string]string{"path": "a/b.txt"}},
+		{"GET", "/files", "/files/*path", map[string]string{"path": ""}},
+		//静态段走不通时回溯到参数段
+		{"GET", "/a/b/c", "/a/:x<string>/c", map[string]string{"x": "b"}},
+		{"GET", "/a/b/d", "/a/b/d", nil},
+		{"GET", "/a/z/d", "", nil},
+		//末尾可省略的参数
+		{"GET", "/list", "/list/:page<int>?", nil},
+		{"GET", "/list/3", "/list/:page<int>?", map[string]string{"page": "3"}},
+		{"GET", "/list/x", "", nil},
+		//以前的写法，:id只匹配数字，末尾的都可以省略
+		{"GET", "/item/5", "/item/:id", map[string]string{"id": "5"}},
+		{"GET", "/item/abc", "", nil},
+		{"GET", "/item", "/item/:id", nil},
+		{"GET", "/tag/1/go", "/tag/:id/:name:", map[string]string{"id": "1", "name": "go"}},
+		{"GET", "/tag/1", "/tag/:id/:name:", map[string]string{"id": "1"}},
+		//按参数类型的优先级尝试
+		{"GET", "/t/12", "/t/:n<int>", map[string]string{"n": "12"}},
+		{"GET", "/t/2026-01-02", "/t/:d<date>", map[string]string{"d": "2026-01-02"}},
+		{"GET", "/t/abc", "/t/:w<alpha>", map[string]string{"w": "abc"}},
+		{"GET", "/t/a-1", "", nil},
+		{"GET", "/u/123e4567-e89b-12d3-a456-426614174000", "/u/:uid<uuid>", map[string]string{"uid": "123e4567-e89b-12d3-a456-426614174000"}},
+		{"GET", "/u/123", "", nil},
+		{"GET", "/slug/hello-world", "/slug/:s<[a-z-]+>", map[string]string{"s": "hello-world"}},
+		{"GET", "/slug/Hello", "", nil},
+		//AddParamType添加的类型，优先级更高的先试，走不通时回溯
+		{"GET", "/h/ff", "/h/:v<hex>", map[string]string{"v": "ff"}},
+		{"GET", "/h/12", "/h/:v<hex>", map[string]string{"v": "12"}},
+		{"GET", "/h/12/x", "/h/:n<int>/x", map[string]string{"n": "12"}},
+		{"GET", "/static/css/a.css", "/static/*rest", map[string]string{"rest": "css/a.css"}},
+		//请求方法
+		{"GET", "/m/get", "/m/get", nil},
+		{"HEAD", "/m/get", "/m/get", nil},
+		{"GET", "/m/post", "", nil},
+		{"GET", "/nothing", "", nil},
+	}
+	for _, tt := range tests {
+		ctx := newTestContext(tt.method, tt.path)
+		r, _ := rs.Lookup(ctx, ctx.Request)
+		got := ""
+		if r != nil {
+			got = r.Config
+		}
+		if got != tt.want {
+			t.Errorf("%s %s matched %q, want %q", tt.method, tt.path, got, tt.want)
+			continue
+		}
+		params := tt.params
+		if params == nil {
+			params = map[string]string{}
+		}
+		if !reflect.DeepEqual(ctx.Input.Args, params) {
+			t.Errorf("%s %s params = %v, want %v", tt.method, tt.path, ctx.Input.Args, params)
+		}
+	}
+}
+
+//缓存命中后捕获的参数要和第一次一样
+func TestTreeLookupCached(t *testing.T) {
+	rs := newTestRouterList("/user/:id<int>", "/files/*path")
+	for i := 0; i < 3; i++ {
+		for path, want := range map[string]string{"/user/7": "7", "/files/a/b": "a/b"} {
+			ctx := newTestContext("GET", path)
+			if r, _ := rs.Lookup(ctx, ctx.Request); r == nil {
+				t.Fatalf("%s not matched", path)
+			}
+			got := ctx.Input.Args["id"] + ctx.Input.Args["path"]
+			if got != want {
+				t.Errorf("round %d %s param = %q, want %q", i, path, got, want)
+			}
+		}
+	}
+	if st := rs.CacheStats(); st.Hits != 4 {
+		t.Errorf("cache hits = %d, want 4", st.Hits)
+	}
+}
+
+func TestTreeMethodNotAllowed(t *testing.T) {
+	rs := newTestRouterList("GET /m", "POST /m", "PUT /n/:id<int>")
+	ctx := newTestContext("DELETE", "/m")
+	r, allowed := rs.Lookup(ctx, ctx.Request)
+	if r != nil {
+		t.Fatalf("DELETE /m matched %q", r.Config)
+	}
+	if want := []string{"GET", "HEAD", "OPTIONS", "POST"}; !reflect.DeepEqual(allowed, want) {
+		t.Errorf("allowed = %v, want %v", allowed, want)
+	}
+	ctx = newTestContext("GET", "/n/x")
+	if _, allowed = rs.Lookup(ctx, ctx.Request); allowed != nil {
+		t.Errorf("GET /n/x allowed = %v, want nil", allowed)
+	}
+}
+
+func TestParsePathPatternErrors(t *testing.T) {
+	for _, config := range []string{
+		"/a/*rest/b",          //通配段不在最后
+		"/a/:id<int>?/b",      //可省略的段不在末尾
+		"/a/:id<int",          //缺少“>”
+		"/a/:<int>",           //没有参数名
+		"/a/:id<[a-z>",        //正则有误
+		"/a/:id<uiid>",        //没有注册的类型
+		"/a/:p<int>?/:q<int>", //可省略的段后面还有不可省略的
+	} {
+		if _, err := parsePathPattern(config); err == nil {
+			t.Errorf("parsePathPattern(%q) should fail", config)
+		}
+	}
+}
+
+//全路径路由和正则路由都能匹配上时，先添加的优先
+func TestLookupOrderAcrossTypes(t *testing.T) {
+	newItem := func(typ int, config string) *ThingoRouterItem {
+		return &ThingoRouterItem{Type: typ, Config: config, Handler: ThingoHandlerFunc(noopHandler)}
+	}
+	re := newItem(RouterTypeRegexp, `^user/([0-9]+)$`)
+	path := newItem(RouterTypePathInfo, "/user/:id<int>")
+	other := newItem(RouterTypePathInfo, "/page/:id<int>")
+	rs := NewThingoRouterList().AddRouters(re, path)
+	ctx := newTestContext("GET", "/user/12")
+	if r, _ := rs.Lookup(ctx, ctx.Request); r != re {
+		t.Errorf("regexp added first: matched %v", r)
+	}
+
+	re = newItem(RouterTypeRegexp, `^(user|page)/([0-9]+)$`)
+	path = newItem(RouterTypePathInfo, "/user/:id<int>")
+	rs = NewThingoRouterList().AddRouters(path, re, other)
+	for p, want := range map[string]*ThingoRouterItem{"/user/12": path, "/page/12": re} {
+		//第二次走缓存
+		for i := 0; i < 2; i++ {
+			ctx = newTestContext("GET", p)
+			if r, _ := rs.Lookup(ctx, ctx.Request); r != want {
+				t.Errorf("%s: matched %v, want %v", p, r.Config, want.Config)
+			}
+		}
+	}
+}
+
+//同样的路径、方法只能有一条路由，参数段按规范化后的校验规则比较
+func TestAddRouterConflict(t *testing.T) {
+	tests := []struct {
+		first, second string
+		conflict      bool
+	}{
+		{"/a/b", "/a/b", true},
+		{"/a/:x<int>", "/a/:y<int>", true},
+		{"/a/:x<[0-9]+>", "/a/:y<int>", true},
+		{`/a/:x<\d+>`, "/a/:y<[0-9]{1,}>", true},
+		{"/a/:id", "/a/:n<int>", true},
+		{"/a/:w<[A-Za-z]+>", "/a/:v<alpha>", true},
+		{"/a/:s<[a-z-]+>", "/a/:t<[-a-z]+>", true},
+		{"/a/:p<int>?", "/a", true},
+		{"/a/*rest", "/a/*path", true},
+		{"GET /m", "/m", true},
+		{"/a/:x<int>", "/a/:y<alpha>", false},
+		{"/a/:x<[a-z]+>", "/a/:y<[a-z0-9]+>", false},
+		{"GET /m", "POST /m", false},
+		{"/a/b", "/a/:x:", false},
+		//不同的类型即使能匹配同样的值也不算冲突，按优先级尝试
+		{"/a/:x<int>", "/a/:v<[0-9a-f]+>", false},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if err := recover(); (err != nil) != tt.conflict {
+					t.Errorf("%q then %q: panic = %v, want conflict %v", tt.first, tt.second, err, tt.conflict)
+				}
+			}()
+			newTestRouterList(tt.first, tt.second)
+		}()
+	}
+}
+
+//和内置类型等价的正则用内置类型的规则
+func TestParamCheckAlias(t *testing.T) {
+	for expr, want := range map[string]string{
+		`[0-9]+`:       "int",
+		`\d+`:          "int",
+		`[[:digit:]]+`: "int",
+		`[a-zA-Z]+`:    "alpha",
+		`[a-z]+`:       "regexp:[a-z]+",
+		`\d{2}`:        "regexp:[0-9][0-9]",
+	} {
+		pc, err := lookupParamCheck(expr)
+		if err != nil {
+			t.Fatalf("lookupParamCheck(%q): %v", expr, err)
+		}
+		if pc.key != want {
+			t.Errorf("lookupParamCheck(%q).key = %q, want %q", expr, pc.key, want)
+		}
+	}
+}
+
+//生成n条路由，一半静态、一半带参数
+func benchmarkRoutes(n int) ([]string, []string) {
+	var configs, paths []string
+	for i := 0; i < n/2; i++ {
+		configs = append(configs, fmt.Sprintf("/static/section%d/page%d", i%50, i))
+		paths = append(paths, fmt.Sprintf("/static/section%d/page%d", i%50, i))
+		configs = append(configs, fmt.Sprintf("/api/v1/res%d/:id<int>/detail", i))
+		paths = append(paths, fmt.Sprintf("/api/v1/res%d/%d/detail", i, i*7))
+	}
+	return configs, paths
+}
+
+func benchmarkLookup(b *testing.B, cacheSize int) {
+	configs, paths := benchmarkRoutes(5000)
+	rs := newTestRouterList(configs...)
+	rs.SetCache(cacheSize, false)
+	ctxs := make([]*context.ThingoContext, len(paths))
+	for i, p := range paths {
+		ctxs[i] = newTestContext("GET", p)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ctx := ctxs[i%len(ctxs)]
+		ctx.Input.ResetParams()
+		if r, _ := rs.Lookup(ctx, ctx.Request); r == nil {
+			b.Fatalf("%s not matched", ctx.Request.URL.Path)
+		}
+	}
+}
+
+//5000条路由，不带缓存
+func BenchmarkLookup(b *testing.B) {
+	benchmarkLookup(b, 0)
+}
+
+//5000条路由，缓存能放下所有的请求
+func BenchmarkLookupCached(b *testing.B) {
+	benchmarkLookup(b, 8192)
+}
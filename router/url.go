@@ -0,0 +1,170 @@
+/**
+ * @author      Liu Yongshuai<liuyongshuai@hotmail.com>
+ * @package     router
+ * @date        2026-10-17 14:20
+ */
+package router
+
+import (
+	"fmt"
+	"net/url"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+)
+
+/**
+按路由名称反向生成URL，params为依次排列的参数名及参数值，如：
+	rs.URLFor("item", "id", 6666, "from", "index")
+全路径路由会用参数填充 :id、:name: 等段，正则路由会按Param里的 aid=$1 这样的配置填充捕获组，
+没有用到的参数会拼成query string追加到后面
+*/
+func (rs *ThingoRouterList) URLFor(name string, params ...interface{}) (string, error) {
+	rs.Mutex.RLock()
+	r, ok := rs.names[name]
+	rs.Mutex.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("router %q not found", name)
+	}
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("router %q: params must be key/value pairs", name)
+	}
+	args := make(map[string]string)
+	var keys []string
+	for i := 0; i < len(params); i += 2 {
+		k := fmt.Sprint(params[i])
+		if _, ok := args[k]; !ok {
+			keys = append(keys, k)
+		}
+		args[k] = fmt.Sprint(params[i+1])
+	}
+
+	var path string
+	var err error
+	switch r.Type {
+	case RouterTypePathInfo:
+		path, err = buildPathInfoURL(r, args)
+	case RouterTypeRegexp:
+		path, err = buildRegexpURL(r, args)
+	default:
+		err = fmt.Errorf("router %q: unsupported router type %d", name, r.Type)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	//剩下的参数作为query string
+	query := url.Values{}
+	for _, k := range keys {
+		if v, ok := args[k]; ok {
+			query.Set(k, v)
+		}
+	}
+	ret := "/" + path
+	if len(query) > 0 {
+		ret += "?" + query.Encode()
+	}
+	return ret, nil
+}
+
+//填充全路径路由，用过的参数会从args里删掉
+func buildPathInfoURL(r *ThingoRouterItem, args map[string]string) (string, error) {
+	var parts []string
+	for _, seg := range r.segments {
+		switch seg.kind {
+		case segStatic:
+			parts = append(parts, seg.value)
+		case segParam:
+			v, ok := args[seg.value]
+			if !ok {
+				if seg.optional {
+					return strings.Join(parts, "/"), nil
+				}
+				return "", fmt.Errorf("router %q: missing param %q", r.Name, seg.value)
+			}
+			if !seg.check.fn(v) {
+				return "", fmt.Errorf("router %q: invalid value %q for param %q", r.Name, v, seg.value)
+			}
+			parts = append(parts, url.PathEscape(v))
+			delete(args, seg.value)
+		case segCatchAll:
+			v := args[seg.value]
+			delete(args, seg.value)
+			for _, p := range strings.Split(strings.Trim(v, "/"), "/") {
+				if p != "" {
+					parts = append(parts, url.PathEscape(p))
+				}
+			}
+		}
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+//填充正则路由的捕获组，用过的参数会从args里删掉
+func buildRegexpURL(r *ThingoRouterItem, args map[string]string) (string, error) {
+	//Param 为 aid=$1&cid=$2 这样的配置，提取出捕获组对应的参数名
+	groups := make(map[int]string)
+	for _, a := range strings.Split(r.Param, "&") {
+		kv := strings.SplitN(a, "=", 2)
+		if len(kv) != 2 || !strings.HasPrefix(kv[1], "$") {
+			continue
+		}
+		if n, err := strconv.Atoi(kv[1][1:]); err == nil {
+			groups[n] = kv[0]
+		}
+	}
+	re, err := syntax.Parse(r.Config, syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	if err := renderRegexp(&buf, re, groups, args); err != nil {
+		return "", fmt.Errorf("router %q: %v", r.Name, err)
+	}
+	path := buf.String()
+	if r.reg != nil && !r.reg.MatchString(path) {
+		return "", fmt.Errorf("router %q: generated path %q does not match %q", r.Name, path, r.Config)
+	}
+	for _, k := range groups {
+		delete(args, k)
+	}
+	return path, nil
+}
+
+//把正则表达式还原成字符串，捕获组用参数值替换，可省略的部分直接省略
+func renderRegexp(buf *strings.Builder, re *syntax.Regexp, groups map[int]string, args map[string]string) error {
+	switch re.Op {
+	case syntax.OpLiteral:
+		buf.WriteString(string(re.Rune))
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if err := renderRegexp(buf, sub, groups, args); err != nil {
+				return err
+			}
+		}
+	case syntax.OpCapture:
+		key, ok := groups[re.Cap]
+		if !ok {
+			return fmt.Errorf("capture group $%d is not bound to any param", re.Cap)
+		}
+		v, ok := args[key]
+		if !ok {
+			return fmt.Errorf("missing param %q", key)
+		}
+		buf.WriteString(url.PathEscape(v))
+	case syntax.OpPlus:
+		return renderRegexp(buf, re.Sub[0], groups, args)
+	case syntax.OpRepeat:
+		for i := 0; i < re.Min; i++ {
+			if err := renderRegexp(buf, re.Sub[0], groups, args); err != nil {
+				return err
+			}
+		}
+	case syntax.OpStar, syntax.OpQuest, syntax.OpEmptyMatch,
+		syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+	default:
+		return fmt.Errorf("can not build url from %q", re.String())
+	}
+	return nil
+}
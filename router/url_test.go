@@ -0,0 +1,106 @@
+/**
+ * @author      Liu Yongshuai<liuyongshuai@hotmail.com>
+ * @package     router
+ * @date        2026-10-18 18:05
+ */
+package router
+
+import (
+	"testing"
+)
+
+//新建一个带名称的路由列表
+func newNamedRouterList() *ThingoRouterList {
+	rs := NewThingoRouterList()
+	for name, config := range map[string]string{
+		"item":   "/item/:id<int>",
+		"legacy": "/old/:id/:name:",
+		"user":   "/user/:name<string>",
+		"list":   "/list/:cat<alpha>/:page<int>?",
+		"static": "/static/*path",
+		"day":    "/day/:d<date>",
+	} {
+		rs.AddRouter(&ThingoRouterItem{Type: RouterTypePathInfo, Config: config, Name: name, Handler: ThingoHandlerFunc(noopHandler)})
+	}
+	rs.AddRouter(&ThingoRouterItem{
+		Type:    RouterTypeRegexp,
+		Config:  `^article/([0-9]+)/([a-z-]+)\.html$`,
+		Param:   "aid=$1&slug=$2",
+		Name:    "article",
+		Handler: ThingoHandlerFunc(noopHandler),
+	})
+	return rs
+}
+
+func TestURLFor(t *testing.T) {
+	rs := newNamedRouterList()
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   string
+	}{
+		{"item", []interface{}{"id", 6666}, "/item/6666"},
+		{"legacy", []interface{}{"id", 1, "name", "tom"}, "/old/1/tom"},
+		{"legacy", []interface{}{"id", 1}, "/old/1"},
+		{"legacy", nil, "/old"},
+		{"user", []interface{}{"name", "a b/c"}, "/user/a%20b%2Fc"},
+		{"day", []interface{}{"d", "2026-10-18"}, "/day/2026-10-18"},
+		//末尾可省略的参数
+		{"list", []interface{}{"cat", "books"}, "/list/books"},
+		{"list", []interface{}{"cat", "books", "page", 2}, "/list/books/2"},
+		//通配段
+		{"static", []interface{}{"path", "css/a b.css"}, "/static/css/a%20b.css"},
+		{"static", []interface{}{"path", "/js/"}, "/static/js"},
+		{"static", nil, "/static"},
+		//没有用到的参数拼成query string，按参数名排序，同名的取最后一个
+		{"item", []interface{}{"id", 1, "q", "a b&c", "from", "index"}, "/item/1?from=index&q=a+b%26c"},
+		{"item", []interface{}{"id", 1, "id", 2}, "/item/2"},
+		{"article", []interface{}{"aid", 12, "slug", "hello-world", "ref", "x"}, "/article/12/hello-world.html?ref=x"},
+	}
+	for _, tt := range tests {
+		got, err := rs.URLFor(tt.name, tt.params...)
+		if err != nil || got != tt.want {
+			t.Errorf("URLFor(%q, %v) = %q, %v, want %q", tt.name, tt.params, got, err, tt.want)
+		}
+	}
+}
+
+func TestURLForErrors(t *testing.T) {
+	rs := newNamedRouterList()
+	tests := []struct {
+		name   string
+		params []interface{}
+	}{
+		{"none", nil},
+		{"item", []interface{}{"id"}},
+		{"item", nil},
+		{"item", []interface{}{"id", "abc"}},
+		{"item", []interface{}{"id", ""}},
+		{"list", []interface{}{"cat", "b2"}},
+		{"list", []interface{}{"page", 2}},
+		{"list", []interface{}{"cat", "books", "page", "x"}},
+		{"day", []interface{}{"d", "2026-13-01"}},
+		{"article", []interface{}{"aid", 12}},
+		//生成的路径不满足正则
+		{"article", []interface{}{"aid", "x", "slug", "hello"}},
+	}
+	for _, tt := range tests {
+		if got, err := rs.URLFor(tt.name, tt.params...); err == nil {
+			t.Errorf("URLFor(%q, %v) = %q, should fail", tt.name, tt.params, got)
+		}
+	}
+}
+
+//生成的URL能再匹配回同一条路由，参数一致
+func TestURLForRoundTrip(t *testing.T) {
+	rs := newNamedRouterList()
+	u, err := rs.URLFor("list", "cat", "books", "page", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := newTestContext("GET", u)
+	r, _ := rs.Lookup(ctx, ctx.Request)
+	if r == nil || r.Name != "list" || ctx.Input.Param("cat") != "books" || ctx.Input.Param("page") != "3" {
+		t.Errorf("%s: matched %v", u, r)
+	}
+}
@@ -0,0 +1,459 @@
+/**
+ * @author      Liu Yongshuai<liuyongshuai@hotmail.com>
+ * @package     websocket
+ * @date        2026-10-17 15:20
+ */
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/liuyongshuai/thingo/context"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+//消息类型，即帧的opcode
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+//关闭码，见RFC 6455 7.4.1
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseMandatoryExtension      = 1010
+	CloseInternalServerErr       = 1011
+)
+
+//控制帧的最大长度
+const maxControlPayload = 125
+
+//读帧时按帧头的长度一次分配内存的上限，更长的边读边扩容
+const maxFramePrealloc = 64 << 10
+
+//收到关闭帧或因协议错误关闭连接时返回的错误
+type CloseError struct {
+	Code int    //关闭码
+	Text string //关闭的原因
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+//是否为正常关闭，即关闭码为1000、1001或没有关闭码
+func IsNormalClose(err error) bool {
+	var ce *CloseError
+	if errors.As(err, &ce) {
+		return ce.Code == CloseNormalClosure || ce.Code == CloseGoingAway || ce.Code == CloseNoStatusReceived
+	}
+	return false
+}
+
+/**
+一个WebSocket连接，由Upgrade或Handler得到
+同一时间只能有一个goroutine读，写可以在多个goroutine里同时进行
+*/
+type Conn struct {
+	Ctx *context.ThingoContext //握手时的请求上下文，可以取路由参数、登录信息等
+
+	conn        net.Conn
+	br          *bufio.Reader
+	opt         *Options
+	readLimit   int64
+	subprotocol string
+
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+	closeSent bool
+	stop      chan struct{} //关闭时停止定时的ping
+
+	pingHandler func(data string) error
+	pongHandler func(data string) error
+}
+
+//新建连接，PingInterval大于0时开始定时发送ping
+func newConn(netConn net.Conn, br *bufio.Reader, opt *Options) *Conn {
+	c := &Conn{conn: netConn, br: br, opt: opt, stop: make(chan struct{})}
+	switch {
+	case opt.ReadLimit == 0:
+		c.readLimit = DefaultReadLimit
+	case opt.ReadLimit > 0:
+		c.readLimit = opt.ReadLimit
+	}
+	if opt.PingInterval > 0 {
+		go c.keepAlive(opt.PingInterval)
+	}
+	return c
+}
+
+//协商好的子协议，没有时为空
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+//客户端的地址
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+//设置单条消息的最大字节数，小于等于0时不限制
+func (c *Conn) SetReadLimit(n int64) {
+	c.readLimit = n
+}
+
+//设置读的超时时间
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+//设置写的超时时间
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+//设置收到ping时的处理函数，默认回复一个同样内容的pong
+func (c *Conn) SetPingHandler(fn func(data string) error) {
+	c.pingHandler = fn
+}
+
+//设置收到pong时的处理函数，默认忽略
+func (c *Conn) SetPongHandler(fn func(data string) error) {
+	c.pongHandler = fn
+}
+
+/**
+读取一条完整的消息，分片的消息会拼接好，期间收到的控制帧会自动处理
+收到关闭帧时回复关闭帧并返回*CloseError，协议错误时发送对应的关闭码后断开
+*/
+func (c *Conn) ReadMessage() (messageType int, data []byte, err error) {
+	for {
+		if c.opt.PingInterval > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(2 * c.opt.PingInterval))
+		}
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch opcode {
+		case PingMessage:
+			if err := c.handlePing(payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if c.pongHandler != nil {
+				if err := c.pongHandler(string(payload)); err != nil {
+					return 0, nil, err
+				}
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, c.handleClose(payload)
+		case 0:
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "expected continuation frame")
+			}
+			messageType = opcode
+		}
+		if c.readLimit > 0 && int64(len(data)+len(payload)) > c.readLimit {
+			return 0, nil, c.fail(CloseMessageTooBig, "message too big")
+		}
+		data = append(data, payload...)
+		if fin {
+			break
+		}
+	}
+	if messageType == TextMessage && !utf8.Valid(data) {
+		return 0, nil, c.fail(CloseInvalidFramePayloadData, "invalid utf-8 text")
+	}
+	return messageType, data, nil
+}
+
+//读取一条消息并按json解码
+func (c *Conn) ReadJSON(v interface{}) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+//读取一帧，校验帧头并去掉掩码
+func (c *Conn) readFrame() (fin bool, opcode int, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(c.br, head[:]); err != nil {
+		return false, 0, nil, c.readError(err)
+	}
+	fin = head[0]&0x80 != 0
+	opcode = int(head[0] & 0x0f)
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "reserved bits set")
+	}
+	switch opcode {
+	case 0, TextMessage, BinaryMessage:
+	case CloseMessage, PingMessage, PongMessage:
+		if !fin {
+			return false, 0, nil, c.fail(CloseProtocolError, "fragmented control frame")
+		}
+	default:
+		return false, 0, nil, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", opcode))
+	}
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "client frame not masked")
+	}
+	length := int64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, c.readError(err)
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, c.readError(err)
+		}
+		if ext[0]&0x80 != 0 {
+			return false, 0, nil, c.fail(CloseProtocolError, "invalid frame length")
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+	if opcode >= CloseMessage && length > maxControlPayload {
+		return false, 0, nil, c.fail(CloseProtocolError, "control frame too long")
+	}
+	if c.readLimit > 0 && length > c.readLimit {
+		return false, 0, nil, c.fail(CloseMessageTooBig, "message too big")
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, c.readError(err)
+	}
+	if payload, err = readPayload(c.br, length); err != nil {
+		return false, 0, nil, c.readError(err)
+	}
+	for i := range payload {
+		payload[i] ^= mask[i&3]
+	}
+	return fin, opcode, payload, nil
+}
+
+/**
+读取帧的内容，帧头里的长度是客户端给的，不能直接按它分配内存
+超过maxFramePrealloc时边读边扩容，内存只按实际收到的数据增长，不限制长度时也不会被一个假的长度撑爆
+*/
+func readPayload(r io.Reader, length int64) ([]byte, error) {
+	if length <= maxFramePrealloc {
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+	}
+	var buf bytes.Buffer
+	buf.Grow(maxFramePrealloc)
+	if _, err := io.CopyN(&buf, r, length); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//读出错时关闭连接，连接断开时返回CloseAbnormalClosure
+func (c *Conn) readError(err error) error {
+	c.closeConn()
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return &CloseError{Code: CloseAbnormalClosure, Text: err.Error()}
+	}
+	return err
+}
+
+//收到ping时的处理
+func (c *Conn) handlePing(payload []byte) error {
+	if c.pingHandler != nil {
+		return c.pingHandler(string(payload))
+	}
+	err := c.WriteMessage(PongMessage, payload)
+	if err == ErrClosed {
+		return nil
+	}
+	return err
+}
+
+//收到关闭帧时回复同样的关闭码，再断开连接
+func (c *Conn) handleClose(payload []byte) error {
+	code, text := CloseNoStatusReceived, ""
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, "invalid close payload")
+	case len(payload) >= 2:
+		code = int(binary.BigEndian.Uint16(payload))
+		text = string(payload[2:])
+		if !validCloseCode(code) {
+			return c.fail(CloseProtocolError, "invalid close code")
+		}
+		if !utf8.ValidString(text) {
+			return c.fail(CloseInvalidFramePayloadData, "invalid utf-8 close reason")
+		}
+	}
+	reply := CloseNormalClosure
+	if code != CloseNoStatusReceived {
+		reply = code
+	}
+	c.CloseWithCode(reply, "")
+	return &CloseError{Code: code, Text: text}
+}
+
+//是否为可以出现在关闭帧里的关闭码
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+//出现协议错误时发送关闭帧并断开
+func (c *Conn) fail(code int, text string) error {
+	c.CloseWithCode(code, text)
+	return &CloseError{Code: code, Text: text}
+}
+
+//发送一条消息，控制帧的内容不能超过125字节
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	switch messageType {
+	case TextMessage, BinaryMessage:
+	case CloseMessage, PingMessage, PongMessage:
+		if len(data) > maxControlPayload {
+			return errors.New("websocket: control frame too long")
+		}
+	default:
+		return fmt.Errorf("websocket: unknown message type %d", messageType)
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrClosed
+	}
+	if messageType == CloseMessage {
+		c.closeSent = true
+	}
+	return c.writeFrame(messageType, data)
+}
+
+//发送一条文本消息
+func (c *Conn) WriteText(text string) error {
+	return c.WriteMessage(TextMessage, []byte(text))
+}
+
+//按json编码后以文本消息发送
+func (c *Conn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, data)
+}
+
+//发送一个ping
+func (c *Conn) Ping(data []byte) error {
+	return c.WriteMessage(PingMessage, data)
+}
+
+//写一帧，服务端发送的帧不加掩码，调用前要拿到writeMu
+func (c *Conn) writeFrame(opcode int, data []byte) error {
+	buf := make([]byte, 0, len(data)+10)
+	buf = append(buf, 0x80|byte(opcode))
+	switch n := len(data); {
+	case n <= 125:
+		buf = append(buf, byte(n))
+	case n <= 0xffff:
+		buf = append(buf, 126, byte(n>>8), byte(n))
+	default:
+		buf = append(buf, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(buf[len(buf)-8:], uint64(n))
+	}
+	buf = append(buf, data...)
+	if c.opt.WriteTimeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.opt.WriteTimeout))
+	}
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+//定时发送ping，连接关闭后停止
+func (c *Conn) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if c.Ping(nil) != nil {
+				return
+			}
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+//发送关闭帧后断开连接，原因超过123字节时按字符截断
+func (c *Conn) CloseWithCode(code int, text string) error {
+	if n := maxControlPayload - 2; len(text) > n {
+		for n > 0 && !utf8.RuneStart(text[n]) {
+			n--
+		}
+		text = text[:n]
+	}
+	payload := make([]byte, 2, 2+len(text))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, text...)
+	err := c.WriteMessage(CloseMessage, payload)
+	c.closeConn()
+	if err == ErrClosed {
+		return nil
+	}
+	return err
+}
+
+//正常关闭连接，可以多次调用
+func (c *Conn) Close() error {
+	return c.CloseWithCode(CloseNormalClosure, "")
+}
+
+//断开底层的连接
+func (c *Conn) closeConn() {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+		c.conn.Close()
+		//先断开连接，让阻塞中的写入返回，再标记为已关闭
+		c.writeMu.Lock()
+		c.closeSent = true
+		c.writeMu.Unlock()
+	})
+}
@@ -0,0 +1,379 @@
+/**
+ * @author      Liu Yongshuai<liuyongshuai@hotmail.com>
+ * @package     websocket
+ * @date        2026-10-18 14:10
+ */
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+//测试用的客户端，通过net.Pipe连到服务端的Conn上
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func newTestPair(t *testing.T, opt *Options) (*Conn, *testClient) {
+	server, client := net.Pipe()
+	if opt == nil {
+		opt = &Options{}
+	}
+	c := newConn(server, bufio.NewReader(server), opt)
+	t.Cleanup(func() {
+		client.Close()
+		c.closeConn()
+	})
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	server.SetDeadline(time.Now().Add(5 * time.Second))
+	return c, &testClient{t: t, conn: client, br: bufio.NewReader(client)}
+}
+
+//按客户端的格式生成一帧，加上掩码，按长度选择7、16、64位的长度字段
+func clientFrame(fin bool, opcode int, payload []byte) []byte {
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	buf := []byte{b0}
+	switch n := len(payload); {
+	case n <= 125:
+		buf = append(buf, 0x80|byte(n))
+	case n <= 0xffff:
+		buf = append(buf, 0x80|126, byte(n>>8), byte(n))
+	default:
+		buf = append(buf, 0x80|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(buf[len(buf)-8:], uint64(n))
+	}
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	buf = append(buf, mask[:]...)
+	for i, b := range payload {
+		buf = append(buf, b^mask[i&3])
+	}
+	return buf
+}
+
+//关闭帧的内容
+func closePayload(code int, text string) []byte {
+	b := make([]byte, 2, 2+len(text))
+	binary.BigEndian.PutUint16(b, uint16(code))
+	return append(b, text...)
+}
+
+//在另一个goroutine里发送若干帧，服务端断开后写入出错的忽略
+func (tc *testClient) send(frames ...[]byte) {
+	go func() {
+		for _, f := range frames {
+			if _, err := tc.conn.Write(f); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+//读取服务端发来的一帧，同时返回帧头里的长度字段
+func (tc *testClient) readFrame() (fin bool, opcode int, lenByte byte, payload []byte) {
+	tc.t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(tc.br, head[:]); err != nil {
+		tc.t.Fatalf("read frame head: %v", err)
+	}
+	if head[1]&0x80 != 0 {
+		tc.t.Fatal("server frame is masked")
+	}
+	lenByte = head[1] & 0x7f
+	n := uint64(lenByte)
+	switch lenByte {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(tc.br, ext[:])
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(tc.br, ext[:])
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(tc.br, payload); err != nil {
+		tc.t.Fatalf("read frame payload: %v", err)
+	}
+	return head[0]&0x80 != 0, int(head[0] & 0x0f), lenByte, payload
+}
+
+//读取一个关闭帧，返回关闭码及原因
+func (tc *testClient) readClose() (int, string) {
+	tc.t.Helper()
+	_, opcode, _, payload := tc.readFrame()
+	if opcode != CloseMessage {
+		tc.t.Fatalf("opcode = %d, want close", opcode)
+	}
+	if len(payload) < 2 {
+		return CloseNoStatusReceived, ""
+	}
+	return int(binary.BigEndian.Uint16(payload)), string(payload[2:])
+}
+
+type readResult struct {
+	typ  int
+	data []byte
+	err  error
+}
+
+//在另一个goroutine里读取一条消息
+func readAsync(c *Conn) chan readResult {
+	ch := make(chan readResult, 1)
+	go func() {
+		typ, data, err := c.ReadMessage()
+		ch <- readResult{typ, data, err}
+	}()
+	return ch
+}
+
+func closeCode(err error) int {
+	var ce *CloseError
+	if errors.As(err, &ce) {
+		return ce.Code
+	}
+	return 0
+}
+
+//7、16、64位三种长度，去掉掩码后内容不变
+func TestReadMessageLengths(t *testing.T) {
+	for _, n := range []int{0, 1, 125, 126, 0xffff, 0x10000} {
+		c, tc := newTestPair(t, &Options{ReadLimit: -1})
+		data := bytes.Repeat([]byte("abcdefg"), n/7+1)[:n]
+		tc.send(clientFrame(true, BinaryMessage, data))
+		typ, got, err := c.ReadMessage()
+		if err != nil {
+			t.Fatalf("len %d: ReadMessage: %v", n, err)
+		}
+		if typ != BinaryMessage || !bytes.Equal(got, data) {
+			t.Errorf("len %d: got type %d len %d", n, typ, len(got))
+		}
+	}
+}
+
+//服务端发出的帧不加掩码，长度字段按内容长度选择
+func TestWriteMessageLengths(t *testing.T) {
+	tests := []struct {
+		n       int
+		lenByte byte
+	}{
+		{0, 0},
+		{125, 125},
+		{126, 126},
+		{0xffff, 126},
+		{0x10000, 127},
+	}
+	for _, tt := range tests {
+		c, tc := newTestPair(t, nil)
+		data := bytes.Repeat([]byte{'x'}, tt.n)
+		go c.WriteMessage(TextMessage, data)
+		fin, opcode, lenByte, payload := tc.readFrame()
+		if !fin || opcode != TextMessage || lenByte != tt.lenByte || !bytes.Equal(payload, data) {
+			t.Errorf("len %d: fin=%v opcode=%d lenByte=%d payload len %d", tt.n, fin, opcode, lenByte, len(payload))
+		}
+	}
+}
+
+//分片的消息拼接起来，中间的ping自动回复pong
+func TestReadMessageFragmented(t *testing.T) {
+	c, tc := newTestPair(t, nil)
+	tc.send(
+		clientFrame(false, TextMessage, []byte("hel")),
+		clientFrame(true, PingMessage, []byte("p1")),
+		clientFrame(false, 0, []byte("lo ")),
+		clientFrame(true, 0, []byte("世界")),
+	)
+	ch := readAsync(c)
+	if _, opcode, _, payload := tc.readFrame(); opcode != PongMessage || string(payload) != "p1" {
+		t.Errorf("got opcode %d payload %q, want pong p1", opcode, payload)
+	}
+	r := <-ch
+	if r.err != nil || r.typ != TextMessage || string(r.data) != "hello 世界" {
+		t.Errorf("ReadMessage = %d %q %v", r.typ, r.data, r.err)
+	}
+}
+
+func TestPingPongHandlers(t *testing.T) {
+	c, tc := newTestPair(t, nil)
+	var pings, pongs []string
+	c.SetPingHandler(func(data string) error { pings = append(pings, data); return nil })
+	c.SetPongHandler(func(data string) error { pongs = append(pongs, data); return nil })
+	tc.send(
+		clientFrame(true, PingMessage, []byte("a")),
+		clientFrame(true, PongMessage, []byte("b")),
+		clientFrame(true, TextMessage, []byte("c")),
+	)
+	if _, data, err := c.ReadMessage(); err != nil || string(data) != "c" {
+		t.Fatalf("ReadMessage = %q %v", data, err)
+	}
+	if len(pings) != 1 || pings[0] != "a" || len(pongs) != 1 || pongs[0] != "b" {
+		t.Errorf("pings = %v, pongs = %v", pings, pongs)
+	}
+}
+
+//协议错误时发出对应的关闭码并断开
+func TestReadMessageProtocolErrors(t *testing.T) {
+	unmasked := clientFrame(true, TextMessage, []byte("hi"))
+	unmasked[1] &^= 0x80
+	reserved := clientFrame(true, TextMessage, []byte("hi"))
+	reserved[0] |= 0x40
+	badLength := clientFrame(true, BinaryMessage, make([]byte, 0x10000))
+	badLength[2] |= 0x80
+
+	tests := []struct {
+		name   string
+		limit  int64
+		frames [][]byte
+		code   int
+	}{
+		{"unmasked", 0, [][]byte{unmasked}, CloseProtocolError},
+		{"reserved bits", 0, [][]byte{reserved}, CloseProtocolError},
+		{"unknown opcode", 0, [][]byte{clientFrame(true, 3, nil)}, CloseProtocolError},
+		{"invalid 64-bit length", 0, [][]byte{badLength}, CloseProtocolError},
+		{"fragmented ping", 0, [][]byte{clientFrame(false, PingMessage, nil)}, CloseProtocolError},
+		{"ping too long", 0, [][]byte{clientFrame(true, PingMessage, make([]byte, 126))}, CloseProtocolError},
+		{"close too long", 0, [][]byte{clientFrame(true, CloseMessage, make([]byte, 126))}, CloseProtocolError},
+		{"unexpected continuation", 0, [][]byte{clientFrame(true, 0, []byte("x"))}, CloseProtocolError},
+		{"expected continuation", 0, [][]byte{
+			clientFrame(false, TextMessage, []byte("a")),
+			clientFrame(true, TextMessage, []byte("b")),
+		}, CloseProtocolError},
+		{"invalid utf-8", 0, [][]byte{clientFrame(true, TextMessage, []byte{0xff, 0xfe})}, CloseInvalidFramePayloadData},
+		{"invalid utf-8 across fragments", 0, [][]byte{
+			clientFrame(false, TextMessage, []byte{0xe4, 0xb8}),
+			clientFrame(true, 0, []byte{'x'}),
+		}, CloseInvalidFramePayloadData},
+		{"frame over limit", 10, [][]byte{clientFrame(true, BinaryMessage, make([]byte, 11))}, CloseMessageTooBig},
+		{"message over limit", 10, [][]byte{
+			clientFrame(false, BinaryMessage, make([]byte, 6)),
+			clientFrame(true, 0, make([]byte, 6)),
+		}, CloseMessageTooBig},
+		{"64-bit frame over default limit", 0, [][]byte{clientFrame(true, BinaryMessage, make([]byte, DefaultReadLimit+1))}, CloseMessageTooBig},
+	}
+	for _, tt := range tests {
+		c, tc := newTestPair(t, &Options{ReadLimit: tt.limit})
+		tc.send(tt.frames...)
+		ch := readAsync(c)
+		if code, _ := tc.readClose(); code != tt.code {
+			t.Errorf("%s: sent close code %d, want %d", tt.name, code, tt.code)
+		}
+		if r := <-ch; closeCode(r.err) != tt.code {
+			t.Errorf("%s: ReadMessage err = %v, want code %d", tt.name, r.err, tt.code)
+		}
+	}
+}
+
+//收到关闭帧时回复关闭帧，返回对方的关闭码及原因
+func TestReadMessageClose(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		code    int //ReadMessage返回的关闭码
+		text    string
+		reply   int //回复的关闭码
+	}{
+		{"normal", closePayload(CloseNormalClosure, "bye"), CloseNormalClosure, "bye", CloseNormalClosure},
+		{"going away", closePayload(CloseGoingAway, ""), CloseGoingAway, "", CloseGoingAway},
+		{"application code", closePayload(4000, "app"), 4000, "app", 4000},
+		{"empty", nil, CloseNoStatusReceived, "", CloseNormalClosure},
+		{"one byte", []byte{3}, CloseProtocolError, "invalid close payload", CloseProtocolError},
+		{"reserved code", closePayload(CloseNoStatusReceived, ""), CloseProtocolError, "invalid close code", CloseProtocolError},
+		{"unassigned code", closePayload(1004, ""), CloseProtocolError, "invalid close code", CloseProtocolError},
+		{"out of range code", closePayload(5000, ""), CloseProtocolError, "invalid close code", CloseProtocolError},
+		{"invalid reason", closePayload(CloseNormalClosure, "\xff"), CloseInvalidFramePayloadData, "invalid utf-8 close reason", CloseInvalidFramePayloadData},
+	}
+	for _, tt := range tests {
+		c, tc := newTestPair(t, nil)
+		tc.send(clientFrame(true, CloseMessage, tt.payload))
+		ch := readAsync(c)
+		if code, _ := tc.readClose(); code != tt.reply {
+			t.Errorf("%s: reply code %d, want %d", tt.name, code, tt.reply)
+		}
+		r := <-ch
+		var ce *CloseError
+		if !errors.As(r.err, &ce) || ce.Code != tt.code || ce.Text != tt.text {
+			t.Errorf("%s: ReadMessage err = %v, want %d %q", tt.name, r.err, tt.code, tt.text)
+		}
+		if normal := tt.code == CloseNormalClosure || tt.code == CloseGoingAway || tt.code == CloseNoStatusReceived; IsNormalClose(r.err) != normal {
+			t.Errorf("%s: IsNormalClose = %v, want %v", tt.name, !normal, normal)
+		}
+		if err := c.WriteText("late"); err != ErrClosed {
+			t.Errorf("%s: write after close = %v, want ErrClosed", tt.name, err)
+		}
+	}
+}
+
+//关闭的原因太长时按字符截断，关闭帧不超过125字节且仍是合法的utf-8
+func TestCloseWithCodeTruncate(t *testing.T) {
+	for _, text := range []string{
+		strings.Repeat("a", 200),
+		"a" + strings.Repeat("中", 60),
+		"ab" + strings.Repeat("😀", 40),
+	} {
+		c, tc := newTestPair(t, nil)
+		go c.CloseWithCode(CloseGoingAway, text)
+		code, reason := tc.readClose()
+		if code != CloseGoingAway {
+			t.Errorf("code = %d, want %d", code, CloseGoingAway)
+		}
+		if len(reason) > maxControlPayload-2 || !utf8.ValidString(reason) || !strings.HasPrefix(text, reason) {
+			t.Errorf("reason len %d valid %v, want a utf-8 prefix of at most 123 bytes", len(reason), utf8.ValidString(reason))
+		}
+		if len(reason) < maxControlPayload-2-3 {
+			t.Errorf("reason truncated to %d bytes, too short", len(reason))
+		}
+	}
+}
+
+func TestWriteMessageErrors(t *testing.T) {
+	c, tc := newTestPair(t, nil)
+	if err := c.WriteMessage(PingMessage, make([]byte, 126)); err == nil {
+		t.Error("ping longer than 125 bytes should fail")
+	}
+	if err := c.WriteMessage(3, nil); err == nil {
+		t.Error("unknown message type should fail")
+	}
+	go c.Close()
+	if code, _ := tc.readClose(); code != CloseNormalClosure {
+		t.Errorf("close code = %d, want %d", code, CloseNormalClosure)
+	}
+	if err := c.WriteText("x"); err != ErrClosed {
+		t.Errorf("write after Close = %v, want ErrClosed", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("second Close = %v", err)
+	}
+}
+
+//不限制长度时，帧头里假的超大长度不会按它分配内存，读到连接断开为止
+func TestReadMessageHugeLength(t *testing.T) {
+	for _, length := range []uint64{1 << 40, 1<<63 - 1} {
+		c, tc := newTestPair(t, &Options{ReadLimit: -1})
+		frame := []byte{0x80 | BinaryMessage, 0x80 | 127, 0, 0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4}
+		binary.BigEndian.PutUint64(frame[2:10], length)
+		ch := readAsync(c)
+		//net.Pipe的写在服务端读完之后才返回，关闭时帧头及数据都已经被读走了
+		for _, b := range [][]byte{frame, make([]byte, maxFramePrealloc+10)} {
+			if _, err := tc.conn.Write(b); err != nil {
+				t.Fatalf("length %d: write: %v", length, err)
+			}
+		}
+		tc.conn.Close()
+		if r := <-ch; closeCode(r.err) != CloseAbnormalClosure {
+			t.Errorf("length %d: ReadMessage err = %v, want code %d", length, r.err, CloseAbnormalClosure)
+		}
+	}
+}
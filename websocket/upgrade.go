@@ -0,0 +1,202 @@
+/**
+ * @author      Liu Yongshuai<liuyongshuai@hotmail.com>
+ * @package     websocket
+ * @date        2026-10-17 15:20
+ */
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"github.com/liuyongshuai/thingo/context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//握手时用来计算Sec-WebSocket-Accept的固定值
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+//单条消息默认的最大字节数
+const DefaultReadLimit = 1 << 20
+
+//升级为WebSocket连接时的配置
+type Options struct {
+	Subprotocols []string                              //服务端支持的子协议，按优先级排列
+	CheckOrigin  func(ctx *context.ThingoContext) bool //校验Origin头，为nil时要求与请求的域名一致，没有Origin头的放行
+	ReadLimit    int64                                 //单条消息的最大字节数，为0时用DefaultReadLimit，小于0时不限制
+	PingInterval time.Duration                         //每隔多久发一次ping，为0时不发，超过两个间隔没收到任何数据时断开
+	WriteTimeout time.Duration                         //单次写入的超时时间，为0时不限制
+}
+
+//WebSocket的处理函数，返回后连接自动关闭
+type HandlerFunc func(conn *Conn)
+
+/**
+把WebSocket的处理函数转为路由的处理函数，如：
+	app.HandleFunc("ws/chat/:room", websocket.Handler(chat), http.MethodGet)
+
+握手失败时按普通请求输出错误，路由分组、中间件、插件对它同样生效
+*/
+func Handler(fn HandlerFunc, opts ...*Options) func(ctx *context.ThingoContext) {
+	var opt *Options
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	return func(ctx *context.ThingoContext) {
+		conn, err := Upgrade(ctx, opt)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fn(conn)
+	}
+}
+
+/**
+把当前请求升级为WebSocket连接
+握手失败时已经设置好了Output里的状态码及错误信息，返回的错误带有StatusCode()
+连接会在请求结束时自动关闭，要在控制层或处理函数返回前用完
+*/
+func Upgrade(ctx *context.ThingoContext, opt *Options) (*Conn, error) {
+	if opt == nil {
+		opt = &Options{}
+	}
+	req := ctx.Request
+	header := ctx.ResponseWriter.Header()
+	if req.Method != http.MethodGet {
+		return nil, handshakeError(ctx, http.StatusMethodNotAllowed, "websocket: method must be GET")
+	}
+	if !headerContainsToken(req.Header, "Connection", "upgrade") || !headerContainsToken(req.Header, "Upgrade", "websocket") {
+		return nil, handshakeError(ctx, http.StatusBadRequest, "websocket: not a websocket handshake")
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		header.Set("Sec-WebSocket-Version", "13")
+		return nil, handshakeError(ctx, http.StatusUpgradeRequired, "websocket: unsupported version")
+	}
+	key := strings.TrimSpace(req.Header.Get("Sec-WebSocket-Key"))
+	if raw, err := base64.StdEncoding.DecodeString(key); err != nil || len(raw) != 16 {
+		return nil, handshakeError(ctx, http.StatusBadRequest, "websocket: invalid Sec-WebSocket-Key")
+	}
+	checkOrigin := opt.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(ctx) {
+		return nil, handshakeError(ctx, http.StatusForbidden, "websocket: origin not allowed")
+	}
+	hj, ok := ctx.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, handshakeError(ctx, http.StatusInternalServerError, "websocket: response does not support hijacking")
+	}
+	subprotocol := selectSubprotocol(req, opt.Subprotocols)
+
+	netConn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, handshakeError(ctx, http.StatusInternalServerError, "websocket: "+err.Error())
+	}
+	//接管后Output不能再输出任何内容
+	ctx.Output.Started = true
+	ctx.Output.Streaming = true
+	netConn.SetDeadline(time.Time{})
+
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	b.WriteString("Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n")
+	if subprotocol != "" {
+		b.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	//带上中间件、插件等已经设置好的头信息及cookie，101响应不能有body相关的头
+	h := header.Clone()
+	for _, k := range []string{"Upgrade", "Connection", "Sec-WebSocket-Accept", "Sec-WebSocket-Protocol",
+		"Content-Length", "Content-Type", "Content-Encoding", "Transfer-Encoding"} {
+		h.Del(k)
+	}
+	for _, c := range ctx.Output.Cookies {
+		h.Add("Set-Cookie", c)
+	}
+	h.Write(&b)
+	b.WriteString("\r\n")
+	if opt.WriteTimeout > 0 {
+		netConn.SetWriteDeadline(time.Now().Add(opt.WriteTimeout))
+	}
+	if _, err := netConn.Write([]byte(b.String())); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	netConn.SetWriteDeadline(time.Time{})
+
+	br := brw.Reader
+	if br == nil {
+		br = bufio.NewReader(netConn)
+	}
+	conn := newConn(netConn, br, opt)
+	conn.Ctx = ctx
+	conn.subprotocol = subprotocol
+	ctx.OnRelease(func() { conn.Close() })
+	return conn, nil
+}
+
+//握手失败时设置输出的错误信息
+func handshakeError(ctx *context.ThingoContext, status int, msg string) error {
+	ctx.Output.SetStatus(status)
+	ctx.Output.AddHeader("Content-Type", "text/plain; charset=utf-8")
+	ctx.Output.SetBody([]byte(msg))
+	return context.NewHTTPError(status, "%s", msg)
+}
+
+//计算Sec-WebSocket-Accept
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+//默认的Origin校验：没有Origin头，或Origin的域名与请求的域名一致
+func sameOrigin(ctx *context.ThingoContext) bool {
+	origin := ctx.Request.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, ctx.Request.Host)
+}
+
+//选出客户端请求的子协议里服务端支持的第一个
+func selectSubprotocol(req *http.Request, supported []string) string {
+	if len(supported) == 0 {
+		return ""
+	}
+	for _, v := range req.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(v, ",") {
+			p = strings.TrimSpace(p)
+			for _, s := range supported {
+				if p == s {
+					return s
+				}
+			}
+		}
+	}
+	return ""
+}
+
+//逗号分隔的头信息里是否包含某一项，不区分大小写
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//连接已经关闭
+var ErrClosed = errors.New("websocket: connection closed")
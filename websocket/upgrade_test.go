@@ -0,0 +1,145 @@
+/**
+ * @author      Liu Yongshuai<liuyongshuai@hotmail.com>
+ * @package     websocket
+ * @date        2026-10-18 14:10
+ */
+package websocket
+
+import (
+	"bufio"
+	"github.com/liuyongshuai/thingo/context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+//RFC 6455 1.3里的示例
+const (
+	testKey    = "dGhlIHNhbXBsZSBub25jZQ=="
+	testAccept = "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="
+)
+
+func newTestContext(w http.ResponseWriter, r *http.Request) *context.ThingoContext {
+	ctx := context.NewThingoContext()
+	ctx.Reset(&w, r)
+	return ctx
+}
+
+func handshakeRequest() *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", testKey)
+	return req
+}
+
+func TestUpgradeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(r *http.Request)
+		opt    *Options
+		status int
+	}{
+		{"method", func(r *http.Request) { r.Method = http.MethodPost }, nil, http.StatusMethodNotAllowed},
+		{"no upgrade", func(r *http.Request) { r.Header.Del("Upgrade") }, nil, http.StatusBadRequest},
+		{"no connection", func(r *http.Request) { r.Header.Set("Connection", "keep-alive") }, nil, http.StatusBadRequest},
+		{"version", func(r *http.Request) { r.Header.Set("Sec-WebSocket-Version", "8") }, nil, http.StatusUpgradeRequired},
+		{"key", func(r *http.Request) { r.Header.Set("Sec-WebSocket-Key", "c2hvcnQ=") }, nil, http.StatusBadRequest},
+		{"origin", func(r *http.Request) { r.Header.Set("Origin", "http://evil.example") }, nil, http.StatusForbidden},
+		{"check origin", func(r *http.Request) {}, &Options{CheckOrigin: func(*context.ThingoContext) bool { return false }}, http.StatusForbidden},
+		//ResponseRecorder不支持Hijack
+		{"hijack", func(r *http.Request) {}, nil, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		req := handshakeRequest()
+		tt.modify(req)
+		ctx := newTestContext(httptest.NewRecorder(), req)
+		conn, err := Upgrade(ctx, tt.opt)
+		if conn != nil || err == nil {
+			t.Errorf("%s: Upgrade should fail", tt.name)
+			continue
+		}
+		if ctx.Output.Status != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, ctx.Output.Status, tt.status)
+		}
+	}
+}
+
+//101响应里带上已经设置好的头信息及cookie，并去掉body相关的头
+func TestUpgradeResponseHeaders(t *testing.T) {
+	done := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := newTestContext(w, r)
+		ctx.Output.AddHeader("X-Frame-Options", "DENY")
+		ctx.Output.AddHeader("Content-Type", "text/html")
+		ctx.Output.AddHeader("Content-Length", "10")
+		ctx.Output.AddCookie("sid", "abc")
+		conn, err := Upgrade(ctx, &Options{Subprotocols: []string{"chat"}})
+		if err != nil {
+			done <- err.Error()
+			return
+		}
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			done <- err.Error()
+		} else {
+			done <- string(data)
+		}
+		conn.Close()
+	}))
+	defer srv.Close()
+
+	nc, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	nc.SetDeadline(time.Now().Add(5 * time.Second))
+	req := handshakeRequest()
+	req.Header.Set("Sec-WebSocket-Protocol", "superchat, chat")
+	req.Host = srv.Listener.Addr().String()
+	req.RequestURI = ""
+	if err := req.Write(nc); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(nc)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+	want := map[string]string{
+		"Upgrade":                "websocket",
+		"Connection":             "Upgrade",
+		"Sec-Websocket-Accept":   testAccept,
+		"Sec-Websocket-Protocol": "chat",
+		"X-Frame-Options":        "DENY",
+		"Content-Type":           "",
+		"Content-Length":         "",
+	}
+	for k, v := range want {
+		if got := resp.Header.Get(k); got != v {
+			t.Errorf("header %s = %q, want %q", k, got, v)
+		}
+		if n := len(resp.Header.Values(k)); v != "" && n != 1 {
+			t.Errorf("header %s sent %d times", k, n)
+		}
+	}
+	if cookies := resp.Cookies(); len(cookies) != 1 || cookies[0].Name != "sid" || cookies[0].Value != "abc" {
+		t.Errorf("cookies = %v", cookies)
+	}
+
+	tc := &testClient{t: t, conn: nc, br: br}
+	tc.send(clientFrame(true, TextMessage, []byte("hello")))
+	if got := <-done; got != "hello" {
+		t.Errorf("server read %q, want hello", got)
+	}
+	if code, _ := tc.readClose(); code != CloseNormalClosure {
+		t.Errorf("close code = %d, want %d", code, CloseNormalClosure)
+	}
+}